@@ -3,58 +3,303 @@ package main
 import (
 	"bufio"
 	"concurrency-examples.git/patterns"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// pattern is one entry in the showcase. Its menu number is its position in
+// patternList; name is how -pattern refers to it. run is the interactive
+// demo and demo runs the pattern's main demo without asking for input; both
+// return early once their context is cancelled.
+type pattern struct {
+	name  string
+	title string
+	run   func(context.Context)
+	demo  func(context.Context) patterns.RunSummary
+}
+
+var patternList = []pattern{
+	{"worker-pool", "Worker Pool", patterns.WorkerPool, patterns.WorkerPoolComparison},
+	{"fan-out-fan-in", "Fan-out/Fan-in", patterns.FanOutFanIn, patterns.FanOutFanInComparison},
+	{"pipeline", "Pipeline", patterns.Pipeline, patterns.PipelineComparison},
+	{"rate-limiter", "Rate Limiter", patterns.RateLimiterDemo, patterns.RateLimiterComparison},
+	{"select-timeout", "Select with Timeout", patterns.SelectTimeout, patterns.SelectTimeoutComparison},
+	{"circuit-breaker", "Circuit Breaker", patterns.CircuitBreakerDemo, patterns.CircuitBreakerLifecycle},
+}
+
+// runAllChoice is the menu number of "Run All", just after the patterns.
+var runAllChoice = len(patternList) + 1
+
 func main() {
+	patternFlag := flag.String("pattern", "", "run a single pattern's demo, by name or menu number, without prompts, and exit")
+	allFlag := flag.Bool("all", false, "run every pattern's demo back-to-back without prompts, and exit")
+	plainFlag := flag.Bool("plain", false, "print ASCII labels instead of emoji (also set by NO_COLOR)")
+	quietFlag := flag.Bool("quiet", false, "leave out per-request output and print only results and summaries")
+	formatFlag := flag.String("format", "text", "output format for -pattern, -all and -bench: text or json")
+	benchFlag := flag.Int("bench", 0, "run the -pattern demo N times and report min/mean/max timings, and exit")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *plainFlag {
+		patterns.SetPlainOutput(true)
+	}
+	if *quietFlag {
+		patterns.SetLogLevel(patterns.LevelQuiet)
+	}
+
+	var selected []pattern
+	switch {
+	case *allFlag:
+		selected = patternList
+	case *patternFlag != "":
+		p, ok := findPattern(*patternFlag)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown pattern %q\n\n", *patternFlag)
+			flag.Usage()
+			os.Exit(2)
+		}
+		selected = []pattern{p}
+	}
+	if *benchFlag != 0 && (*benchFlag < 1 || *patternFlag == "" || *allFlag) {
+		fmt.Fprintln(os.Stderr, "-bench needs a positive run count and a single -pattern")
+		os.Exit(2)
+	}
+
+	switch *formatFlag {
+	case "text":
+	case "json":
+		if selected == nil {
+			fmt.Fprintln(os.Stderr, "-format=json needs -pattern or -all")
+			os.Exit(2)
+		}
+		if *benchFlag > 0 {
+			break
+		}
+		ctx, stop := interruptContext()
+		defer stop()
+		if err := writeJSON(ctx, os.Stdout, selected, *allFlag); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing JSON:", err)
+			os.Exit(1)
+		}
+		exitIfInterrupted(ctx)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %q\n\n", *formatFlag)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *benchFlag > 0 {
+		ctx, stop := interruptContext()
+		defer stop()
+		if err := runBench(ctx, os.Stdout, selected[0], *benchFlag, *formatFlag == "json"); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing results:", err)
+			os.Exit(1)
+		}
+		exitIfInterrupted(ctx)
+		return
+	}
+	if *allFlag {
+		ctx, stop := interruptContext()
+		defer stop()
+		runAll(ctx, os.Stdout, patternList)
+		exitIfInterrupted(ctx)
+		return
+	}
+	if selected != nil {
+		ctx, stop := interruptContext()
+		defer stop()
+		selected[0].demo(ctx)
+		exitIfInterrupted(ctx)
+		return
+	}
+
 	fmt.Println("=== Go Concurrency Patterns Showcase ===")
 	fmt.Println()
-	
+
+	runMenu(bufio.NewReader(os.Stdin))
+}
+
+// runMenu shows the menu and runs the chosen patterns until the user picks
+// Exit or input runs out.
+func runMenu(reader *bufio.Reader) {
 	for {
 		showMenu()
-		choice := getUserInput()
+		choice := getUserInput(reader)
 		
-		switch choice {
-		case 1:
-			patterns.WorkerPool()
-		case 2:
-			patterns.FanOutFanIn()
-		case 3:
-			patterns.Pipeline()
-		case 4:
-			patterns.RateLimiter()
-		case 5:
-			patterns.SelectTimeout()
-		case 6:
-			patterns.CircuitBreakerDemo()
-		case 0:
+		switch {
+		case choice >= 1 && choice <= len(patternList):
+			ctx, stop := interruptContext()
+			patternList[choice-1].run(ctx)
+			stop()
+		case choice == runAllChoice:
+			ctx, stop := interruptContext()
+			runAll(ctx, os.Stdout, patternList)
+			stop()
+		case choice == 0:
 			fmt.Println("Goodbye!")
 			return
 		default:
-			fmt.Println("Invalid choice. Please try again.\n")
+			fmt.Println("Invalid choice. Please try again.")
+			fmt.Println()
+		}
+	}
+}
+
+// runAll runs the non-interactive demo of each pattern in turn, announcing
+// each one on w, and returns their summaries. Once ctx is cancelled the
+// running demo stops early and the remaining ones are skipped, so the last
+// summary is the cancelled one.
+func runAll(ctx context.Context, w io.Writer, list []pattern) []patterns.RunSummary {
+	var summaries []patterns.RunSummary
+	for i, p := range list {
+		fmt.Fprintf(w, "##### [%d/%d] %s #####\n\n", i+1, len(list), p.title)
+		summaries = append(summaries, p.demo(ctx))
+		if ctx.Err() != nil {
+			fmt.Fprintf(w, "\nInterrupted: %d of %d patterns ran.\n", len(summaries), len(list))
+			fmt.Fprintln(w)
+			return summaries
+		}
+	}
+	fmt.Fprintln(w, "All patterns finished.")
+	fmt.Fprintln(w)
+	return summaries
+}
+
+// interruptContext returns a context that is cancelled on the first Ctrl-C,
+// letting a running demo stop cleanly and report what it got through. The
+// handler is removed as soon as it fires, so a second Ctrl-C kills the
+// program the usual way. Call stop once the demos are done.
+func interruptContext() (ctx context.Context, stop context.CancelFunc) {
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// exitIfInterrupted exits with the conventional status for SIGINT if ctx was
+// cancelled by Ctrl-C.
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		os.Exit(130)
+	}
+}
+
+// writeJSON runs the non-interactive demos of list with their usual output
+// silenced and writes their summaries to w as JSON: an array when asArray is
+// set, otherwise the single summary of the only pattern. If ctx is cancelled
+// the summaries cover only the demos that ran, the last one marked
+// cancelled.
+func writeJSON(ctx context.Context, w io.Writer, list []pattern, asArray bool) error {
+	defer patterns.SetOutput(patterns.Output())
+	patterns.SetOutput(io.Discard)
+
+	summaries := runAll(ctx, io.Discard, list)
+	encoder := json.NewEncoder(w)
+	if asArray {
+		return encoder.Encode(summaries)
+	}
+	return encoder.Encode(summaries[0])
+}
+
+// runBench runs the non-interactive demo of p n times with its usual output
+// silenced and writes the spread of its timings to w, as a table or as JSON.
+// If ctx is cancelled it reports the runs that finished.
+func runBench(ctx context.Context, w io.Writer, p pattern, n int, asJSON bool) error {
+	defer patterns.SetOutput(patterns.Output())
+	patterns.SetOutput(io.Discard)
+
+	if !asJSON {
+		fmt.Fprintf(w, "Benchmarking %s over %d runs...\n\n", p.title, n)
+	}
+	result := patterns.Bench(ctx, n, p.demo)
+	if asJSON {
+		return json.NewEncoder(w).Encode(result)
+	}
+
+	if len(result.Runs) == 0 {
+		fmt.Fprintln(w, "Interrupted before any run finished.")
+		return nil
+	}
+	if len(result.Runs) < n {
+		fmt.Fprintf(w, "Interrupted: %d of %d runs finished.\n\n", len(result.Runs), n)
+	}
+	fmt.Fprintf(w, "%-12s %12s %12s %12s\n", "", "min", "mean", "max")
+	printBenchRow(w, "Concurrent", result.Concurrent)
+	if result.Sequential.Max > 0 {
+		printBenchRow(w, "Sequential", result.Sequential)
+		fmt.Fprintf(w, "\nAverage speedup: %.2fx over %d runs\n", result.Speedup, len(result.Runs))
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+func printBenchRow(w io.Writer, name string, stats patterns.DurationStats) {
+	fmt.Fprintf(w, "%-12s %12v %12v %12v\n", name, stats.Min.Round(time.Millisecond),
+		stats.Mean.Round(time.Millisecond), stats.Max.Round(time.Millisecond))
+}
+
+// findPattern looks a pattern up by its name or its menu number.
+func findPattern(arg string) (pattern, bool) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(patternList) {
+			return pattern{}, false
+		}
+		return patternList[n-1], true
+	}
+
+	for _, p := range patternList {
+		if strings.EqualFold(p.name, arg) {
+			return p, true
 		}
 	}
+	return pattern{}, false
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without flags an interactive menu is shown.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Patterns:")
+	for i, p := range patternList {
+		fmt.Fprintf(out, "  %d, %-16s %s\n", i+1, p.name, p.title)
+	}
 }
 
 func showMenu() {
 	fmt.Println("Available Concurrency Patterns:")
-	fmt.Println("1. Worker Pool")
-	fmt.Println("2. Fan-out/Fan-in")
-	fmt.Println("3. Pipeline")
-	fmt.Println("4. Rate Limiter")
-	fmt.Println("5. Select with Timeout")
-	fmt.Println("6. Circuit Breaker")
+	for i, p := range patternList {
+		fmt.Printf("%d. %s\n", i+1, p.title)
+	}
+	fmt.Printf("%d. Run All\n", runAllChoice)
 	fmt.Println("0. Exit")
-	fmt.Print("Select a pattern to run (0-6): ")
+	fmt.Printf("Select a pattern to run (0-%d): ", runAllChoice)
 }
 
-func getUserInput() int {
-	reader := bufio.NewReader(os.Stdin)
+// getUserInput reads the next menu choice, returning -1 for anything that is
+// not a number. Once input is exhausted, as when stdin is closed or a piped
+// script runs out, it returns 0 so the menu exits instead of asking forever.
+func getUserInput(reader *bufio.Reader) int {
 	input, err := reader.ReadString('\n')
-	if err != nil {
+	if err == io.EOF && strings.TrimSpace(input) == "" {
+		fmt.Println()
+		return 0
+	}
+	if err != nil && err != io.EOF {
 		fmt.Println("Error reading input:", err)
 		return -1
 	}
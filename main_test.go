@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"concurrency-examples.git/patterns"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePattern is a pattern whose demo reports the given timings at once,
+// checking that the demo output has been silenced.
+func fakePattern(t *testing.T, name string, concurrent, sequential time.Duration) pattern {
+	return pattern{
+		name:  name,
+		title: strings.ToUpper(name),
+		demo: func(ctx context.Context) patterns.RunSummary {
+			if patterns.Output() != io.Discard {
+				t.Errorf("%s demo ran with its output showing", name)
+			}
+			return patterns.RunSummary{Pattern: name, Concurrent: concurrent, Sequential: sequential, Cancelled: ctx.Err() != nil}
+		},
+	}
+}
+
+// cancellingPattern is a pattern whose demo cancels the run it is part of.
+func cancellingPattern(name string, cancel context.CancelFunc) pattern {
+	return pattern{
+		name:  name,
+		title: name,
+		demo: func(ctx context.Context) patterns.RunSummary {
+			cancel()
+			return patterns.RunSummary{Pattern: name, Cancelled: true}
+		},
+	}
+}
+
+// captureDemoOutput sends the demo output to a buffer for the rest of the
+// test, restoring the previous writer afterwards.
+func captureDemoOutput(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := patterns.Output()
+	patterns.SetOutput(&buf)
+	t.Cleanup(func() { patterns.SetOutput(previous) })
+	return &buf
+}
+
+func TestFindPattern(t *testing.T) {
+	tests := []struct {
+		arg    string
+		want   string
+		wantOK bool
+	}{
+		{"worker-pool", "worker-pool", true},
+		{"Circuit-Breaker", "circuit-breaker", true},
+		{"1", "worker-pool", true},
+		{"6", "circuit-breaker", true},
+		{"0", "", false},
+		{"7", "", false},
+		{"-1", "", false},
+		{"no-such-pattern", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		p, ok := findPattern(tt.arg)
+		if p.name != tt.want || ok != tt.wantOK {
+			t.Errorf("findPattern(%q) = %q, %v; want %q, %v", tt.arg, p.name, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// Every pattern can be picked by name or by its menu number
+	for i, want := range patternList {
+		for _, arg := range []string{want.name, strconv.Itoa(i + 1)} {
+			if p, ok := findPattern(arg); !ok || p.name != want.name {
+				t.Errorf("findPattern(%q) = %q, %v; want %q", arg, p.name, ok, want.name)
+			}
+		}
+	}
+}
+
+func TestPatternListIsComplete(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, p := range patternList {
+		if p.name == "" || p.title == "" || p.run == nil || p.demo == nil {
+			t.Errorf("pattern %+v is missing a field", p)
+		}
+		if seen[p.name] {
+			t.Errorf("pattern name %q used twice", p.name)
+		}
+		seen[p.name] = true
+	}
+	if runAllChoice != len(patternList)+1 {
+		t.Errorf("Run All is menu choice %d, want %d", runAllChoice, len(patternList)+1)
+	}
+}
+
+func TestGetUserInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"number", "3\n", 3},
+		{"surrounding space", "  2 \n", 2},
+		{"not a number", "abc\n", -1},
+		{"empty line", "\n", -1},
+		{"last line without newline", "4", 4},
+		{"end of input", "", 0},
+	}
+	for _, tt := range tests {
+		if got := getUserInput(bufio.NewReader(strings.NewReader(tt.input))); got != tt.want {
+			t.Errorf("%s: getUserInput(%q) = %d, want %d", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRunMenuStopsAtEOF(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"no input", ""},
+		{"invalid choices first", "abc\n99\n"},
+		{"explicit exit", "0\n"},
+	}
+	for _, tt := range tests {
+		done := make(chan struct{})
+		go func() {
+			runMenu(bufio.NewReader(strings.NewReader(tt.input)))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: runMenu did not return at the end of input", tt.name)
+		}
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	captureDemoOutput(t)
+	list := []pattern{fakePattern(t, "one", time.Second, 0), fakePattern(t, "two", time.Second, 0)}
+
+	var buf bytes.Buffer
+	patterns.SetOutput(io.Discard) // runAll leaves silencing to its callers
+	summaries := runAll(context.Background(), &buf, list)
+	if len(summaries) != 2 || summaries[0].Pattern != "one" || summaries[1].Pattern != "two" {
+		t.Errorf("summaries = %+v", summaries)
+	}
+	for _, want := range []string{"[1/2] ONE", "[2/2] TWO", "All patterns finished."} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output %q does not contain %q", buf.String(), want)
+		}
+	}
+}
+
+func TestRunAllCancelled(t *testing.T) {
+	captureDemoOutput(t)
+	patterns.SetOutput(io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	list := []pattern{
+		fakePattern(t, "one", time.Second, 0),
+		cancellingPattern("two", cancel),
+		fakePattern(t, "three", time.Second, 0),
+	}
+
+	var buf bytes.Buffer
+	summaries := runAll(ctx, &buf, list)
+	if len(summaries) != 2 || !summaries[1].Cancelled {
+		t.Errorf("summaries = %+v, want two with the last cancelled", summaries)
+	}
+	if !strings.Contains(buf.String(), "Interrupted: 2 of 3 patterns ran.") || strings.Contains(buf.String(), "three") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	demoOutput := captureDemoOutput(t)
+	list := []pattern{fakePattern(t, "one", time.Second, 2*time.Second), fakePattern(t, "two", time.Second, 0)}
+
+	tests := []struct {
+		name    string
+		list    []pattern
+		asArray bool
+		want    []string
+	}{
+		{"single", list[:1], false, []string{"one"}},
+		{"array", list, true, []string{"one", "two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeJSON(context.Background(), &buf, tt.list, tt.asArray); err != nil {
+				t.Fatal(err)
+			}
+			if patterns.Output() != io.Writer(demoOutput) {
+				t.Error("writeJSON did not restore the previous demo output")
+			}
+
+			// Summaries are written as flat objects with lower-case keys
+			var got []map[string]any
+			if tt.asArray {
+				if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+					t.Fatalf("output %q is not a JSON array: %v", buf.String(), err)
+				}
+			} else {
+				var summary map[string]any
+				if err := json.Unmarshal(buf.Bytes(), &summary); err != nil {
+					t.Fatalf("output %q is not a JSON object: %v", buf.String(), err)
+				}
+				got = append(got, summary)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d summaries, want %d", len(got), len(tt.want))
+			}
+			for i, name := range tt.want {
+				if got[i]["pattern"] != name {
+					t.Errorf("summary %d is for %v, want %q", i, got[i]["pattern"], name)
+				}
+			}
+			if got[0]["speedup"] != 2.0 {
+				t.Errorf("first summary speedup = %v, want 2", got[0]["speedup"])
+			}
+		})
+	}
+	if demoOutput.Len() != 0 {
+		t.Errorf("writeJSON let demo output through: %q", demoOutput.String())
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	demoOutput := captureDemoOutput(t)
+	withBaseline := fakePattern(t, "base", 100*time.Millisecond, 300*time.Millisecond)
+	concurrentOnly := fakePattern(t, "solo", 100*time.Millisecond, 0)
+
+	tests := []struct {
+		name    string
+		p       pattern
+		want    []string
+		notWant []string
+	}{
+		{"with a sequential baseline", withBaseline, []string{"Benchmarking BASE over 3 runs", "Concurrent", "Sequential", "Average speedup: 3.00x over 3 runs"}, nil},
+		{"concurrent only", concurrentOnly, []string{"Benchmarking SOLO over 3 runs", "Concurrent"}, []string{"Sequential", "speedup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := runBench(context.Background(), &buf, tt.p, 3, false); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output %q does not contain %q", buf.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(buf.String(), notWant) {
+					t.Errorf("output %q contains %q", buf.String(), notWant)
+				}
+			}
+			if patterns.Output() != io.Writer(demoOutput) {
+				t.Error("runBench did not restore the previous demo output")
+			}
+		})
+	}
+}
+
+func TestRunBenchJSON(t *testing.T) {
+	captureDemoOutput(t)
+	var buf bytes.Buffer
+	if err := runBench(context.Background(), &buf, fakePattern(t, "base", time.Second, 2*time.Second), 2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var result struct {
+		Pattern string  `json:"pattern"`
+		Runs    int     `json:"runs"`
+		Speedup float64 `json:"speedup"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("output %q is not JSON: %v", buf.String(), err)
+	}
+	if result.Pattern != "base" || result.Runs != 2 || result.Speedup != 2 {
+		t.Errorf("result = %+v, want 2 runs of base with a 2x speedup", result)
+	}
+}
+
+func TestRunBenchCancelled(t *testing.T) {
+	captureDemoOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var buf bytes.Buffer
+	if err := runBench(ctx, &buf, cancellingPattern("stop", cancel), 3, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Interrupted before any run finished.") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestPrintBenchRow(t *testing.T) {
+	var buf bytes.Buffer
+	printBenchRow(&buf, "Concurrent", patterns.DurationStats{
+		Min:  1234567 * time.Nanosecond,
+		Mean: 2 * time.Millisecond,
+		Max:  time.Second,
+	})
+	want := "Concurrent            1ms          2ms           1s\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
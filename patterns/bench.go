@@ -0,0 +1,99 @@
+package patterns
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// BenchResult gathers repeated runs of one pattern's non-interactive demo.
+// The demos sleep for random lengths of time, so a single run is noisy;
+// the spread over several runs says more about what concurrency buys.
+type BenchResult struct {
+	Pattern    string
+	Runs       []RunSummary
+	Concurrent DurationStats
+	Sequential DurationStats // zero for patterns without a sequential baseline
+	Speedup    float64       // mean of the per-run speedups
+}
+
+// DurationStats is the spread of a set of durations.
+type DurationStats struct {
+	Min, Mean, Max time.Duration
+}
+
+// Bench runs demo n times and summarizes the runs. The demo prints as usual,
+// so callers will usually send its output elsewhere with SetOutput first. If
+// ctx is cancelled the run in progress is dropped and the result covers the
+// runs that finished.
+func Bench(ctx context.Context, n int, demo func(context.Context) RunSummary) BenchResult {
+	if n < 1 {
+		panic("patterns: Bench needs at least one run")
+	}
+
+	var result BenchResult
+	for i := 0; i < n; i++ {
+		run := demo(ctx)
+		result.Pattern = run.Pattern
+		if run.Cancelled {
+			break
+		}
+		result.Runs = append(result.Runs, run)
+	}
+
+	var concurrent, sequential []time.Duration
+	var speedups float64
+	for _, run := range result.Runs {
+		concurrent = append(concurrent, run.Concurrent)
+		if run.Sequential > 0 {
+			sequential = append(sequential, run.Sequential)
+			speedups += run.Speedup()
+		}
+	}
+	result.Concurrent = durationStats(concurrent)
+	result.Sequential = durationStats(sequential)
+	if len(sequential) > 0 {
+		result.Speedup = speedups / float64(len(sequential))
+	}
+	return result
+}
+
+func durationStats(durations []time.Duration) DurationStats {
+	if len(durations) == 0 {
+		return DurationStats{}
+	}
+
+	stats := DurationStats{Min: durations[0], Max: durations[0]}
+	var total time.Duration
+	for _, d := range durations {
+		stats.Min = min(stats.Min, d)
+		stats.Max = max(stats.Max, d)
+		total += d
+	}
+	stats.Mean = total / time.Duration(len(durations))
+	return stats
+}
+
+// MarshalJSON writes the result with durations in milliseconds, for example
+// {"concurrentMs":{"max":360.1,"mean":351.2,"min":340.8},"pattern":"worker-pool","runs":3,"sequentialMs":{...},"speedup":2.86}.
+// The sequential figures are left out when the pattern has no baseline.
+func (r BenchResult) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{
+		"pattern":      r.Pattern,
+		"runs":         len(r.Runs),
+		"concurrentMs": r.Concurrent.milliseconds(),
+	}
+	if r.Sequential.Max > 0 {
+		fields["sequentialMs"] = r.Sequential.milliseconds()
+		fields["speedup"] = r.Speedup
+	}
+	return json.Marshal(fields)
+}
+
+func (s DurationStats) milliseconds() map[string]float64 {
+	return map[string]float64{
+		"min":  milliseconds(s.Min),
+		"mean": milliseconds(s.Mean),
+		"max":  milliseconds(s.Max),
+	}
+}
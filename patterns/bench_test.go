@@ -0,0 +1,152 @@
+package patterns
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// fakeDemo returns a demo that reports the given runs in turn, cancelling
+// ctx's run once the list runs out.
+func fakeDemo(runs []RunSummary) func(context.Context) RunSummary {
+	i := 0
+	return func(ctx context.Context) RunSummary {
+		if i >= len(runs) {
+			return RunSummary{Pattern: "fake", Cancelled: true}
+		}
+		run := runs[i]
+		i++
+		return run
+	}
+}
+
+func TestBench(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name       string
+		runs       []RunSummary
+		n          int
+		wantRuns   int
+		concurrent DurationStats
+		sequential DurationStats
+		speedup    float64
+	}{
+		{
+			name: "with baseline",
+			runs: []RunSummary{
+				{Pattern: "fake", Concurrent: 100 * ms, Sequential: 400 * ms},
+				{Pattern: "fake", Concurrent: 200 * ms, Sequential: 400 * ms},
+				{Pattern: "fake", Concurrent: 300 * ms, Sequential: 600 * ms},
+			},
+			n:          3,
+			wantRuns:   3,
+			concurrent: DurationStats{Min: 100 * ms, Mean: 200 * ms, Max: 300 * ms},
+			sequential: DurationStats{Min: 400 * ms, Mean: 1400 * ms / 3, Max: 600 * ms},
+			speedup:    (4.0 + 2.0 + 2.0) / 3,
+		},
+		{
+			name: "without baseline",
+			runs: []RunSummary{
+				{Pattern: "fake", Concurrent: 50 * ms},
+				{Pattern: "fake", Concurrent: 150 * ms},
+			},
+			n:          2,
+			wantRuns:   2,
+			concurrent: DurationStats{Min: 50 * ms, Mean: 100 * ms, Max: 150 * ms},
+		},
+		{
+			name: "cancelled run is dropped",
+			runs: []RunSummary{
+				{Pattern: "fake", Concurrent: 80 * ms},
+			},
+			n:          3,
+			wantRuns:   1,
+			concurrent: DurationStats{Min: 80 * ms, Mean: 80 * ms, Max: 80 * ms},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Bench(context.Background(), tt.n, fakeDemo(tt.runs))
+			if result.Pattern != "fake" {
+				t.Errorf("Pattern = %q, want %q", result.Pattern, "fake")
+			}
+			if len(result.Runs) != tt.wantRuns {
+				t.Errorf("got %d runs, want %d", len(result.Runs), tt.wantRuns)
+			}
+			if result.Concurrent != tt.concurrent {
+				t.Errorf("Concurrent = %+v, want %+v", result.Concurrent, tt.concurrent)
+			}
+			if result.Sequential != tt.sequential {
+				t.Errorf("Sequential = %+v, want %+v", result.Sequential, tt.sequential)
+			}
+			if diff := result.Speedup - tt.speedup; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Speedup = %v, want %v", result.Speedup, tt.speedup)
+			}
+		})
+	}
+}
+
+func TestBenchPanicsWithoutRuns(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Bench with n=0 did not panic")
+		}
+	}()
+	Bench(context.Background(), 0, fakeDemo(nil))
+}
+
+func TestBenchResultJSON(t *testing.T) {
+	tests := []struct {
+		name           string
+		result         BenchResult
+		wantSequential bool
+	}{
+		{
+			name: "with baseline",
+			result: BenchResult{
+				Pattern:    "pipeline",
+				Runs:       make([]RunSummary, 3),
+				Concurrent: DurationStats{Min: time.Millisecond, Mean: 2 * time.Millisecond, Max: 3 * time.Millisecond},
+				Sequential: DurationStats{Min: 4 * time.Millisecond, Mean: 5 * time.Millisecond, Max: 6 * time.Millisecond},
+				Speedup:    2.5,
+			},
+			wantSequential: true,
+		},
+		{
+			name: "without baseline",
+			result: BenchResult{
+				Pattern:    "circuit-breaker",
+				Runs:       make([]RunSummary, 3),
+				Concurrent: DurationStats{Min: time.Millisecond, Mean: 2 * time.Millisecond, Max: 3 * time.Millisecond},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Pattern      string             `json:"pattern"`
+				Runs         int                `json:"runs"`
+				ConcurrentMs map[string]float64 `json:"concurrentMs"`
+				SequentialMs map[string]float64 `json:"sequentialMs"`
+				Speedup      *float64           `json:"speedup"`
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Pattern != tt.result.Pattern || got.Runs != 3 {
+				t.Errorf("got pattern %q with %d runs, want %q with 3", got.Pattern, got.Runs, tt.result.Pattern)
+			}
+			if got.ConcurrentMs["min"] != 1 || got.ConcurrentMs["mean"] != 2 || got.ConcurrentMs["max"] != 3 {
+				t.Errorf("concurrentMs = %v", got.ConcurrentMs)
+			}
+			if hasSequential := got.SequentialMs != nil && got.Speedup != nil; hasSequential != tt.wantSequential {
+				t.Errorf("sequential figures present = %v, want %v (%s)", hasSequential, tt.wantSequential, data)
+			}
+		})
+	}
+}
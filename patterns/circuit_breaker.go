@@ -1,8 +1,11 @@
 package patterns
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"runtime/debug"
 	"sync"
 	"time"
 )
@@ -18,129 +21,526 @@ const (
 func (cs CircuitState) String() string {
 	switch cs {
 	case CLOSED:
-		return "🟢 CLOSED"
+		return label("🟢 CLOSED", "[CLOSED]")
 	case OPEN:
-		return "🔴 OPEN"
+		return label("🔴 OPEN", "[OPEN]")
 	case HALF_OPEN:
-		return "🟡 HALF_OPEN"
+		return label("🟡 HALF_OPEN", "[HALF_OPEN]")
 	default:
-		return "❓ UNKNOWN"
+		return label("❓ UNKNOWN", "[UNKNOWN]")
 	}
 }
 
+// ErrOpenCircuit is returned by Call when the breaker rejects a request
+// without invoking the protected function.
+var ErrOpenCircuit = errors.New("circuit breaker is open")
+
+// ErrCallTimeout is returned by Call when the protected function runs longer
+// than the breaker's call timeout. It counts as a failure.
+var ErrCallTimeout = errors.New("circuit breaker call timed out")
+
+// PanicError is returned by Call when the protected function panics. The
+// panic counts as a failure like any other error. Worker pools report
+// panicking jobs with the same type.
+type PanicError struct {
+	Value interface{}
+	Stack []byte // only set when stack capture is enabled
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in protected call: %v", e.Value)
+}
+
+// CircuitBreakerMetrics are running totals kept by a breaker. Failures are
+// split into TotalErrors (fn returned an error or panicked) and
+// TotalTimeouts (fn exceeded the call timeout).
+type CircuitBreakerMetrics struct {
+	TotalCalls      int // every call, including rejected ones
+	TotalSuccesses  int
+	TotalErrors     int
+	TotalTimeouts   int
+	TotalRejections int
+}
+
 type CircuitBreaker struct {
 	state          CircuitState
 	failureCount   int
 	lastFailure    time.Time
 	failureThreshold int
+	successThreshold int // consecutive HALF_OPEN successes needed to close
+	successCount   int
+	minimumRequests int // calls required in the current window before tripping
+	requestCount   int
+	failureRate    float64       // > 0 switches to failure-rate mode
+	window         time.Duration // sliding window used in failure-rate mode
+	outcomes       []callOutcome
 	timeout        time.Duration
+	callTimeout    time.Duration // 0 lets fn run for as long as it needs
+	metrics        CircuitBreakerMetrics
+	capturePanicStack bool
+	jitter         time.Duration
+	probeDelay     time.Duration // jitter rolled for the current OPEN period
+	probeSampleRate float64      // chance a post-timeout request is the probe; 0 admits the first
+	rng            *rand.Rand    // nil uses the global math/rand source
+	now            func() time.Time
+	onRecovery     func()
+	onStateChange  func(from, to CircuitState)
+	pendingHooks   []func() // queued under the lock, run once it is released
+	openedAt       time.Time     // start of the current outage (CLOSED → OPEN)
+	longestOutage  time.Duration
 	mutex          sync.RWMutex
 }
 
+type callOutcome struct {
+	at     time.Time
+	failed bool
+}
+
 func NewCircuitBreaker(threshold int, timeout time.Duration) *CircuitBreaker {
-	return &CircuitBreaker{
-		state:            CLOSED,
-		failureThreshold: threshold,
-		timeout:          timeout,
+	return NewCircuitBreakerOpts(WithFailureThreshold(threshold), WithTimeout(timeout))
+}
+
+// NewCircuitBreakerRate creates a breaker that opens when the fraction of
+// failed calls within the trailing window reaches ratio (e.g. 0.6 for 60%),
+// but only once the window holds at least minRequests calls, so a couple of
+// failures on a quiet service don't trip it. It panics if minRequests is
+// less than one.
+func NewCircuitBreakerRate(ratio float64, window time.Duration, minRequests int, timeout time.Duration) *CircuitBreaker {
+	if minRequests < 1 {
+		panic(fmt.Sprintf("patterns: circuit breaker minimum requests must be at least 1, got %d", minRequests))
 	}
+	return NewCircuitBreakerOpts(WithFailureRate(ratio, window), WithMinimumRequests(minRequests), WithTimeout(timeout))
 }
 
 func (cb *CircuitBreaker) Call(fn func() error) error {
 	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+	defer cb.unlock()
 
+	cb.metrics.TotalCalls++
 	if cb.state == OPEN {
-		if time.Since(cb.lastFailure) > cb.timeout {
-			cb.state = HALF_OPEN
+		if cb.now().Sub(cb.lastFailure) > cb.timeout+cb.probeDelay && cb.admitProbe() {
+			cb.setState(HALF_OPEN)
 			cb.failureCount = 0
+			cb.successCount = 0
 		} else {
-			return fmt.Errorf("circuit breaker is OPEN")
+			cb.metrics.TotalRejections++
+			return ErrOpenCircuit
 		}
 	}
 
-	err := fn()
+	if cb.state == CLOSED {
+		cb.requestCount++
+	}
+
+	err := cb.invoke(fn)
+	if cb.state == CLOSED && cb.failureRate > 0 {
+		cb.recordOutcome(err != nil)
+	}
+
 	if err != nil {
+		if errors.Is(err, ErrCallTimeout) {
+			cb.metrics.TotalTimeouts++
+		} else {
+			cb.metrics.TotalErrors++
+		}
 		cb.failureCount++
 		
 		if cb.state == HALF_OPEN {
-			cb.state = OPEN
-			cb.lastFailure = time.Now()
+			cb.trip()
 		} else {
-			cb.lastFailure = time.Now()
-			if cb.failureCount >= cb.failureThreshold {
-				cb.state = OPEN
+			cb.lastFailure = cb.now()
+			if cb.shouldTrip() {
+				cb.trip()
 			}
 		}
 		return err
 	}
 
 	// Success case
+	cb.metrics.TotalSuccesses++
 	if cb.state == HALF_OPEN {
-		cb.state = CLOSED
+		cb.successCount++
+		if cb.successCount >= cb.successThreshold {
+			cb.setState(CLOSED)
+			if outage := cb.now().Sub(cb.openedAt); outage > cb.longestOutage {
+				cb.longestOutage = outage
+			}
+			cb.queueHook(cb.onRecovery)
+		}
 	}
 	cb.failureCount = 0
 	return nil
 }
 
+// unlock releases the mutex and then runs any hooks queued while it was
+// held, so hooks are free to call back into the breaker.
+func (cb *CircuitBreaker) unlock() {
+	hooks := cb.pendingHooks
+	cb.pendingHooks = nil
+	cb.mutex.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+func (cb *CircuitBreaker) queueHook(hook func()) {
+	if hook != nil {
+		cb.pendingHooks = append(cb.pendingHooks, hook)
+	}
+}
+
+// setState changes state and queues the state-change hook, if any.
+func (cb *CircuitBreaker) setState(to CircuitState) {
+	from := cb.state
+	if from == to {
+		return
+	}
+	cb.state = to
+	if onStateChange := cb.onStateChange; onStateChange != nil {
+		cb.queueHook(func() { onStateChange(from, to) })
+	}
+}
+
+// recordOutcome adds a call result to the sliding window and drops results
+// that have aged out of it.
+func (cb *CircuitBreaker) recordOutcome(failed bool) {
+	now := cb.now()
+	cb.outcomes = append(cb.outcomes, callOutcome{at: now, failed: failed})
+
+	cutoff := now.Add(-cb.window)
+	i := 0
+	for i < len(cb.outcomes) && !cb.outcomes[i].at.After(cutoff) {
+		i++
+	}
+	cb.outcomes = cb.outcomes[i:]
+}
+
+// shouldTrip reports whether a CLOSED breaker has seen enough failures to
+// open, using either the consecutive-failure count or the failure rate.
+func (cb *CircuitBreaker) shouldTrip() bool {
+	if cb.failureRate > 0 {
+		total := len(cb.outcomes)
+		if total == 0 || total < cb.minimumRequests {
+			return false
+		}
+		var failures int
+		for _, outcome := range cb.outcomes {
+			if outcome.failed {
+				failures++
+			}
+		}
+		return float64(failures)/float64(total) >= cb.failureRate
+	}
+	return cb.failureCount >= cb.failureThreshold && cb.requestCount >= cb.minimumRequests
+}
+
+// trip moves the breaker to OPEN and rolls a fresh jitter so that breakers
+// opened at the same moment don't all probe the service at the same instant.
+func (cb *CircuitBreaker) trip() {
+	if cb.state == CLOSED {
+		cb.openedAt = cb.now()
+	}
+	cb.setState(OPEN)
+	cb.lastFailure = cb.now()
+	cb.requestCount = 0
+	cb.outcomes = nil
+	cb.probeDelay = 0
+	if cb.jitter > 0 {
+		cb.probeDelay = time.Duration(cb.randFloat64() * float64(cb.jitter))
+	}
+}
+
+// admitProbe decides whether a request arriving after the OPEN timeout
+// becomes the HALF_OPEN probe. With sampling enabled each request wins with
+// probability probeSampleRate, so the probe isn't always the first caller.
+func (cb *CircuitBreaker) admitProbe() bool {
+	if cb.probeSampleRate <= 0 || cb.probeSampleRate >= 1 {
+		return true
+	}
+	return cb.randFloat64() < cb.probeSampleRate
+}
+
+func (cb *CircuitBreaker) randFloat64() float64 {
+	if cb.rng != nil {
+		return cb.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+// invoke runs fn, giving up with ErrCallTimeout if a call timeout is set and
+// fn takes longer. fn cannot be interrupted, so on timeout it keeps running
+// in the background until it returns; its result is then discarded.
+func (cb *CircuitBreaker) invoke(fn func() error) error {
+	captureStack := cb.capturePanicStack
+	if cb.callTimeout <= 0 {
+		return protect(fn, captureStack)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- protect(fn, captureStack)
+	}()
+
+	timer := time.NewTimer(cb.callTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrCallTimeout
+	}
+}
+
+// protect runs fn, converting a panic into a *PanicError so that a
+// misbehaving dependency is treated as a failure instead of crashing the
+// caller.
+func protect(fn func() error, captureStack bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr := &PanicError{Value: r}
+			if captureStack {
+				panicErr.Stack = debug.Stack()
+			}
+			err = panicErr
+		}
+	}()
+	return fn()
+}
+
+// SetCapturePanicStack controls whether a *PanicError returned by Call
+// includes the stack trace of the recovered panic.
+func (cb *CircuitBreaker) SetCapturePanicStack(enabled bool) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.capturePanicStack = enabled
+}
+
+// SetOnRecovery registers fn to be called each time a HALF_OPEN probe
+// succeeds and the breaker closes again. It is not called for ordinary
+// successful calls while CLOSED.
+func (cb *CircuitBreaker) SetOnRecovery(fn func()) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.onRecovery = fn
+}
+
+// SetMinimumRequests sets how many calls the breaker must observe while
+// CLOSED (or within the window, in failure-rate mode) before the failure
+// threshold is evaluated. Below that volume the breaker stays CLOSED no
+// matter how many calls fail.
+func (cb *CircuitBreaker) SetMinimumRequests(n int) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.minimumRequests = n
+}
+
+// SetJitter adds a random delay in [0, jitter) to the OPEN timeout each time
+// the breaker trips. Zero (the default) disables jitter.
+func (cb *CircuitBreaker) SetJitter(jitter time.Duration) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.jitter = jitter
+}
+
+// SetRandSource replaces the random source used by the breaker, mainly so
+// tests can use a seeded generator.
+func (cb *CircuitBreaker) SetRandSource(rng *rand.Rand) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.rng = rng
+}
+
+// Metrics returns a snapshot of the breaker's running totals.
+func (cb *CircuitBreaker) Metrics() CircuitBreakerMetrics {
+	cb.mutex.RLock()
+	defer cb.mutex.RUnlock()
+	return cb.metrics
+}
+
+// LongestOutage returns the longest time the breaker has spent continuously
+// away from CLOSED, counting failed HALF_OPEN probes as part of the same
+// outage. An outage still in progress is included.
+func (cb *CircuitBreaker) LongestOutage() time.Duration {
+	cb.mutex.RLock()
+	defer cb.mutex.RUnlock()
+
+	longest := cb.longestOutage
+	if cb.state != CLOSED {
+		if current := cb.now().Sub(cb.openedAt); current > longest {
+			longest = current
+		}
+	}
+	return longest
+}
+
+// Reset returns the breaker to CLOSED and clears its counters, metrics and
+// outage statistics. Configuration such as thresholds and hooks is kept.
+func (cb *CircuitBreaker) Reset() {
+	cb.mutex.Lock()
+	defer cb.unlock()
+
+	cb.setState(CLOSED)
+	cb.failureCount = 0
+	cb.successCount = 0
+	cb.requestCount = 0
+	cb.outcomes = nil
+	cb.lastFailure = time.Time{}
+	cb.probeDelay = 0
+	cb.openedAt = time.Time{}
+	cb.longestOutage = 0
+	cb.metrics = CircuitBreakerMetrics{}
+}
+
+// setClock replaces the breaker's time source so tests can move time forward
+// without sleeping.
+func (cb *CircuitBreaker) setClock(now func() time.Time) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.now = now
+}
+
+// openTimeout is how long the breaker stays OPEN after tripping before it
+// admits a probe, including any jitter rolled for the current OPEN period.
+func (cb *CircuitBreaker) openTimeout() time.Duration {
+	cb.mutex.RLock()
+	defer cb.mutex.RUnlock()
+	return cb.timeout + cb.probeDelay
+}
+
 func (cb *CircuitBreaker) GetState() CircuitState {
 	cb.mutex.RLock()
 	defer cb.mutex.RUnlock()
 	return cb.state
 }
 
-func CircuitBreakerDemo() {
-	fmt.Println("=== Circuit Breaker Pattern ===")
-	fmt.Println("Preventing cascading failures by monitoring service health")
-	fmt.Println("Use case: External API calls with automatic failure detection")
-	fmt.Println()
-
+// CircuitBreakerDemo runs the interactive circuit breaker menu. Cancelling
+// ctx stops the full lifecycle demo before its next request and leaves the
+// menu.
+func CircuitBreakerDemo(ctx context.Context) {
+	circuitBreakerHeader()
+	var settings cbDemoSettings
 	for {
-		fmt.Println("Circuit Breaker Demo Options:")
-		fmt.Println("1. 🟢 CLOSED state demo (healthy service)")
-		fmt.Println("2. 🔴 OPEN state demo (failing service)")
-		fmt.Println("3. 🟡 HALF_OPEN state demo (recovery attempt)")
-		fmt.Println("4. ❌ No Circuit Breaker (comparison)")
-		fmt.Println("5. 🔄 Full Lifecycle Demo")
-		fmt.Println("0. Back to main menu")
-		fmt.Print("Select demo (0-5): ")
+		console.Println("Circuit Breaker Demo Options:")
+		console.Println("1. 🟢 CLOSED state demo (healthy service)")
+		console.Println("2. 🔴 OPEN state demo (failing service)")
+		console.Println("3. 🟡 HALF_OPEN state demo (recovery attempt)")
+		console.Println("4. ❌ No Circuit Breaker (comparison)")
+		console.Println("5. 🔄 Full Lifecycle Demo")
+		console.Println("6. ⚙️  Configure breaker settings")
+		console.Println("0. Back to main menu")
+		console.Print("Select demo (0-6): ")
 
 		var choice int
 		fmt.Scanf("%d", &choice)
-		fmt.Println()
+		console.Println()
 
 		switch choice {
 		case 1:
-			runClosedStateDemo()
+			runClosedStateDemo(settings.newBreaker(5 * time.Second))
 		case 2:
-			runOpenStateDemo()
+			runOpenStateDemo(settings.newBreaker(5 * time.Second))
 		case 3:
-			runHalfOpenStateDemo()
+			runHalfOpenStateDemo(settings.newBreaker(2 * time.Second))
 		case 4:
 			runNoCircuitBreakerDemo()
 		case 5:
-			runFullLifecycleDemo()
+			runFullLifecycleDemo(ctx, settings.newBreaker(3*time.Second))
+		case 6:
+			settings = configureDemoSettings(settings)
 		case 0:
 			return
 		default:
-			fmt.Println("Invalid choice. Please try again.\n")
+			console.Println("Invalid choice. Please try again.")
+			console.Println()
 		}
 		
-		fmt.Println("\nPress Enter to continue...")
+		if ctx.Err() != nil {
+			return
+		}
+		console.Println("\nPress Enter to continue...")
 		fmt.Scanf("\n")
-		fmt.Println()
+		console.Println()
+	}
+}
+
+// CircuitBreakerLifecycle runs the full lifecycle demo with the default
+// breaker, without asking for input. Cancelling ctx stops it before the
+// next request.
+func CircuitBreakerLifecycle(ctx context.Context) RunSummary {
+	circuitBreakerHeader()
+	start := time.Now()
+	result := runFullLifecycleDemo(ctx, cbDemoSettings{}.newBreaker(3*time.Second))
+
+	summary := RunSummary{
+		Pattern:    "circuit-breaker",
+		Completed:  result.Successful,
+		Concurrent: time.Since(start),
+		Details: map[string]any{
+			"successful": result.Successful,
+			"failed":     result.Failed,
+			"blocked":    result.Blocked,
+		},
+	}
+	cancelled(ctx, &summary)
+	return summary
+}
+
+func circuitBreakerHeader() {
+	console.Println("=== Circuit Breaker Pattern ===")
+	console.Println("Preventing cascading failures by monitoring service health")
+	console.Println("Use case: External API calls with automatic failure detection")
+	console.Println()
+}
+
+// cbDemoSettings holds the user's breaker tuning for the demos. Zero values
+// keep each demo's built-in defaults.
+type cbDemoSettings struct {
+	threshold int
+	timeout   time.Duration
+}
+
+func (s cbDemoSettings) newBreaker(defaultTimeout time.Duration) *CircuitBreaker {
+	threshold := 3
+	if s.threshold > 0 {
+		threshold = s.threshold
 	}
+	timeout := defaultTimeout
+	if s.timeout > 0 {
+		timeout = s.timeout
+	}
+	return NewCircuitBreaker(threshold, timeout)
+}
+
+func configureDemoSettings(s cbDemoSettings) cbDemoSettings {
+	console.Println("⚙️  === Configure Circuit Breaker ===")
+	console.Println("Press Enter to keep the current value")
+	console.Println()
+
+	threshold := s.threshold
+	if threshold == 0 {
+		threshold = 3
+	}
+	s.threshold = promptInt("Failure threshold", threshold, 1)
+	s.timeout = promptDuration("Open timeout (0s = each demo's default)", s.timeout)
+
+	timeout := "each demo's default"
+	if s.timeout > 0 {
+		timeout = s.timeout.String()
+	}
+	console.Printf("\n🔧 Demos will use threshold %d, timeout %s\n", s.threshold, timeout)
+	return s
 }
 
-func runClosedStateDemo() {
-	fmt.Println("🟢 === CLOSED State Demo ===")
-	fmt.Println("Circuit is closed - all requests pass through normally")
-	fmt.Println()
+func runClosedStateDemo(cb *CircuitBreaker) {
+	console.Println("🟢 === CLOSED State Demo ===")
+	console.Println("Circuit is closed - all requests pass through normally")
+	console.Println()
 
-	cb := NewCircuitBreaker(3, 5*time.Second)
 	var successful, failed int
 
 	for i := 1; i <= 10; i++ {
-		fmt.Printf("Request %d: ", i)
+		console.Detailf("Request %d: ", i)
 		
 		err := cb.Call(func() error {
 			return simulateHealthyService()
@@ -148,245 +548,330 @@ func runClosedStateDemo() {
 
 		if err != nil {
 			failed++
-			fmt.Printf("❌ Failed - %v\n", err)
+			console.Detailf("❌ Failed - %v\n", err)
 		} else {
 			successful++
-			fmt.Printf("✅ Success (State: %s)\n", cb.GetState())
+			console.Detailf("✅ Success (State: %s)\n", cb.GetState())
 		}
 		time.Sleep(200 * time.Millisecond)
 	}
 
-	fmt.Printf("\n📊 Results: %d successful, %d failed\n", successful, failed)
-	fmt.Printf("🔧 Circuit remained CLOSED - all requests processed\n")
+	console.Printf("\n📊 Results: %d successful, %d failed\n", successful, failed)
+	console.Printf("🔧 Circuit remained CLOSED - all requests processed\n")
 }
 
-func runOpenStateDemo() {
-	fmt.Println("🔴 === OPEN State Demo ===")
-	fmt.Println("Circuit is open - requests are blocked to protect failing service")
-	fmt.Println()
+func runOpenStateDemo(cb *CircuitBreaker) {
+	console.Println("🔴 === OPEN State Demo ===")
+	console.Println("Circuit is open - requests are blocked to protect failing service")
+	console.Println()
 
-	cb := NewCircuitBreaker(3, 5*time.Second)
 	var successful, failed, blocked int
 
 	// First, trigger the circuit to open by simulating failures
-	fmt.Println("Triggering circuit to open with failures...")
-	for i := 1; i <= 3; i++ {
-		cb.Call(func() error {
-			return fmt.Errorf("service unavailable")
-		})
-	}
+	console.Println("Triggering circuit to open with failures...")
+	tripBreaker(cb, "service unavailable")
 
 	// Now show blocked requests
 	for i := 1; i <= 8; i++ {
-		fmt.Printf("Request %d: ", i)
+		console.Detailf("Request %d: ", i)
 		
 		err := cb.Call(func() error {
 			return simulateHealthyService()
 		})
 
 		if err != nil {
-			if err.Error() == "circuit breaker is OPEN" {
+			if errors.Is(err, ErrOpenCircuit) {
 				blocked++
-				fmt.Printf("🛑 BLOCKED by circuit breaker (State: %s)\n", cb.GetState())
+				console.Detailf("🛑 BLOCKED by circuit breaker (State: %s)\n", cb.GetState())
 			} else {
 				failed++
-				fmt.Printf("❌ Failed - %v\n", err)
+				console.Detailf("❌ Failed - %v\n", err)
 			}
 		} else {
 			successful++
-			fmt.Printf("✅ Success (State: %s)\n", cb.GetState())
+			console.Detailf("✅ Success (State: %s)\n", cb.GetState())
 		}
 		time.Sleep(200 * time.Millisecond)
 	}
 
-	fmt.Printf("\n📊 Results: %d successful, %d failed, %d blocked\n", successful, failed, blocked)
-	fmt.Printf("🛡️  Circuit breaker protected the failing service from %d requests\n", blocked)
+	console.Printf("\n📊 Results: %d successful, %d failed, %d blocked\n", successful, failed, blocked)
+	console.Printf("🛡️  Circuit breaker protected the failing service from %d requests\n", blocked)
 }
 
-func runHalfOpenStateDemo() {
-	fmt.Println("🟡 === HALF_OPEN State Demo ===")
-	fmt.Println("Circuit allows ONE test request to check service recovery")
-	fmt.Println()
+func runHalfOpenStateDemo(cb *CircuitBreaker) {
+	console.Println("🟡 === HALF_OPEN State Demo ===")
+	console.Println("Circuit allows ONE test request to check service recovery")
+	console.Println()
 
-	cb := NewCircuitBreaker(3, 2*time.Second)
 	var successful, failed, blocked int
 
 	// Trigger circuit to open
-	fmt.Println("Opening circuit with failures...")
-	for i := 1; i <= 3; i++ {
-		cb.Call(func() error {
-			return fmt.Errorf("service down")
-		})
-	}
-	fmt.Printf("Circuit State: %s\n\n", cb.GetState())
+	console.Println("Opening circuit with failures...")
+	tripBreaker(cb, "service down")
+	console.Printf("Circuit State: %s\n\n", cb.GetState())
 
 	// Wait for timeout to allow half-open
-	fmt.Println("⏰ Waiting for timeout to allow recovery test...")
-	time.Sleep(2100 * time.Millisecond)
+	console.Println("⏰ Waiting for timeout to allow recovery test...")
+	time.Sleep(cb.openTimeout() + 100*time.Millisecond)
 	
 	// First cycle: Failed recovery test
-	fmt.Printf("Circuit State: %s (timeout expired, ready for test)\n", cb.GetState())
-	fmt.Println("→ Next request will transition to HALF_OPEN for testing")
+	console.Printf("Circuit State: %s (timeout expired, ready for test)\n", cb.GetState())
+	console.Println("→ Next request will transition to HALF_OPEN for testing")
 	
-	fmt.Print("Test Request 1: ")
+	console.Print("Test Request 1: ")
 	err := cb.Call(func() error {
 		return fmt.Errorf("service still failing")
 	})
 	
 	if err != nil {
-		if err.Error() == "circuit breaker is OPEN" {
+		if errors.Is(err, ErrOpenCircuit) {
 			blocked++
-			fmt.Printf("🛑 BLOCKED")
+			console.Printf("🛑 BLOCKED")
 		} else {
 			failed++
-			fmt.Printf("❌ Failed - %v", err)
+			console.Printf("❌ Failed - %v", err)
 		}
 	} else {
 		successful++
-		fmt.Printf("✅ Success!")
+		console.Printf("✅ Success!")
 	}
-	fmt.Printf(" (State after call: %s)\n", cb.GetState())
-	fmt.Println("→ Test failed, circuit returned to OPEN\n")
+	console.Printf(" (State after call: %s)\n", cb.GetState())
+	console.Println("→ Test failed, circuit returned to OPEN")
+	console.Println()
 	
 	// Show blocking during OPEN
 	for i := 2; i <= 4; i++ {
-		fmt.Printf("Request %d: ", i)
+		console.Detailf("Request %d: ", i)
 		err := cb.Call(func() error {
 			return simulateHealthyService()
 		})
 		
-		if err != nil && err.Error() == "circuit breaker is OPEN" {
+		if err != nil && errors.Is(err, ErrOpenCircuit) {
 			blocked++
-			fmt.Printf("🛑 BLOCKED (State: %s)\n", cb.GetState())
+			console.Detailf("🛑 BLOCKED (State: %s)\n", cb.GetState())
 		}
 		time.Sleep(200 * time.Millisecond)
 	}
 	
 	// Second cycle: Successful recovery
-	fmt.Println("\n⏰ Waiting for next recovery window...")
-	time.Sleep(2100 * time.Millisecond)
+	console.Println("\n⏰ Waiting for next recovery window...")
+	time.Sleep(cb.openTimeout() + 100*time.Millisecond)
 	
-	fmt.Printf("Circuit State: %s (timeout expired, ready for test)\n", cb.GetState())
-	fmt.Println("→ Next request will transition to HALF_OPEN for testing")
+	console.Printf("Circuit State: %s (timeout expired, ready for test)\n", cb.GetState())
+	console.Println("→ Next request will transition to HALF_OPEN for testing")
 	
-	fmt.Print("Test Request 5: ")
+	console.Print("Test Request 5: ")
 	err = cb.Call(func() error {
 		return simulateHealthyService() // This will succeed
 	})
 	
 	if err != nil {
-		if err.Error() == "circuit breaker is OPEN" {
+		if errors.Is(err, ErrOpenCircuit) {
 			blocked++
-			fmt.Printf("🛑 BLOCKED")
+			console.Printf("🛑 BLOCKED")
 		} else {
 			failed++
-			fmt.Printf("❌ Failed - %v", err)
+			console.Printf("❌ Failed - %v", err)
 		}
 	} else {
 		successful++
-		fmt.Printf("✅ Success!")
+		console.Printf("✅ Success!")
 	}
-	fmt.Printf(" (State after call: %s)\n", cb.GetState())
-	fmt.Println("→ Test succeeded, circuit is now CLOSED and healthy!\n")
+	console.Printf(" (State after call: %s)\n", cb.GetState())
+	console.Println("→ Test succeeded, circuit is now CLOSED and healthy!")
+	console.Println()
+
+	console.Printf("📊 Results: %d successful, %d failed, %d blocked\n", successful, failed, blocked)
+	console.Printf("🔄 HALF_OPEN allows exactly ONE test request to determine recovery\n")
+}
 
-	fmt.Printf("📊 Results: %d successful, %d failed, %d blocked\n", successful, failed, blocked)
-	fmt.Printf("🔄 HALF_OPEN allows exactly ONE test request to determine recovery\n")
+// maxTripCalls caps the failing calls tripBreaker makes, in case cb is
+// configured so that it never opens.
+const maxTripCalls = 100
+
+// tripBreaker fails calls through cb until it opens. It works the same in
+// consecutive-failure and failure-rate mode, whatever the thresholds.
+func tripBreaker(cb *CircuitBreaker, reason string) {
+	for i := 0; i < maxTripCalls && cb.GetState() != OPEN; i++ {
+		cb.Call(func() error {
+			return errors.New(reason)
+		})
+	}
 }
 
 func runNoCircuitBreakerDemo() {
-	fmt.Println("❌ === No Circuit Breaker Demo ===")
-	fmt.Println("Direct calls to failing service - shows the problem circuit breakers solve")
-	fmt.Println()
+	console.Println("❌ === No Circuit Breaker Demo ===")
+	console.Println("Direct calls to failing service - shows the problem circuit breakers solve")
+	console.Println()
 
 	var successful, failed int
 	
 	for i := 1; i <= 10; i++ {
-		fmt.Printf("Request %d: ", i)
+		console.Detailf("Request %d: ", i)
 		
 		err := simulateFailingService()
 		if err != nil {
 			failed++
-			fmt.Printf("❌ Failed - %v (wasted resources!)\n", err)
+			console.Detailf("❌ Failed - %v (wasted resources!)\n", err)
 		} else {
 			successful++
-			fmt.Printf("✅ Success\n")
+			console.Detailf("✅ Success\n")
 		}
 		time.Sleep(200 * time.Millisecond)
 	}
 
-	fmt.Printf("\n📊 Results: %d successful, %d failed\n", successful, failed)
-	fmt.Printf("⚠️  Without circuit breaker: %d requests wasted on failing service!\n", failed)
-	fmt.Printf("🔥 This could cause cascading failures in production!\n")
+	console.Printf("\n📊 Results: %d successful, %d failed\n", successful, failed)
+	console.Printf("⚠️  Without circuit breaker: %d requests wasted on failing service!\n", failed)
+	console.Printf("🔥 This could cause cascading failures in production!\n")
 }
 
-func runFullLifecycleDemo() {
-	fmt.Println("🔄 === Full Circuit Breaker Lifecycle ===")
-	fmt.Println("Watch circuit breaker automatically handle service degradation and recovery")
-	fmt.Println()
+// LifecycleResult tallies the outcome of every request in a lifecycle run.
+type LifecycleResult struct {
+	Successful, Failed, Blocked int
+}
 
-	cb := NewCircuitBreaker(3, 3*time.Second)
-	var successful, failed, blocked int
+// LifecyclePhases describes a lifecycle run: a healthy phase, a degrading
+// phase that should open the circuit, and a recovery phase started once the
+// OPEN timeout has elapsed. Nil services fall back to the simulated ones.
+type LifecyclePhases struct {
+	Healthy, Degrading, Recovery int
 
-	// Phase 1: Healthy service (CLOSED)
-	fmt.Println("📡 Phase 1: Healthy service...")
-	for i := 1; i <= 5; i++ {
-		fmt.Printf("Request %d: ", i)
-		err := cb.Call(simulateHealthyService)
-		if err != nil {
-			failed++
-			fmt.Printf("❌ Failed (State: %s)\n", cb.GetState())
-		} else {
-			successful++
-			fmt.Printf("✅ Success (State: %s)\n", cb.GetState())
-		}
-		time.Sleep(300 * time.Millisecond)
+	HealthyService    func() error
+	FailingService    func() error
+	RecoveringService func() error
+
+	Pause         time.Duration // delay after each healthy/degrading request
+	RecoveryPause time.Duration // delay after each recovery request
+}
+
+func DefaultLifecyclePhases() LifecyclePhases {
+	return LifecyclePhases{
+		Healthy:       5,
+		Degrading:     5,
+		Recovery:      5,
+		Pause:         300 * time.Millisecond,
+		RecoveryPause: 400 * time.Millisecond,
 	}
+}
 
-	// Phase 2: Service starts failing (CLOSED → OPEN)
-	fmt.Println("\n💥 Phase 2: Service degrading...")
-	for i := 6; i <= 10; i++ {
-		fmt.Printf("Request %d: ", i)
-		err := cb.Call(simulateFailingService)
-		if err != nil {
-			if err.Error() == "circuit breaker is OPEN" {
-				blocked++
-				fmt.Printf("🛑 BLOCKED (State: %s)\n", cb.GetState())
+// lifecycleHooks lets the interactive demo narrate a run as it happens.
+type lifecycleHooks struct {
+	phase   func(phase int)
+	request func(phase, request int, err error, state CircuitState)
+}
+
+// RunLifecycle drives cb through the healthy, degrading and recovery phases
+// and returns the request counts without printing anything.
+func RunLifecycle(cb *CircuitBreaker, phases LifecyclePhases) LifecycleResult {
+	return runLifecycle(context.Background(), cb, phases, lifecycleHooks{})
+}
+
+// runLifecycle is RunLifecycle with narration hooks. Once ctx is done it
+// sends no more requests and returns the counts so far.
+func runLifecycle(ctx context.Context, cb *CircuitBreaker, phases LifecyclePhases, hooks lifecycleHooks) LifecycleResult {
+	healthy := phases.HealthyService
+	if healthy == nil {
+		healthy = simulateHealthyService
+	}
+	failing := phases.FailingService
+	if failing == nil {
+		failing = simulateFailingService
+	}
+	recovering := phases.RecoveringService
+	if recovering == nil {
+		recovering = simulateRecoveringService
+	}
+
+	var result LifecycleResult
+	request := 0
+	runPhase := func(phase, count int, service func() error, pause time.Duration) {
+		for i := 0; i < count && ctx.Err() == nil; i++ {
+			request++
+			err := cb.Call(service)
+			if err != nil {
+				if errors.Is(err, ErrOpenCircuit) {
+					result.Blocked++
+				} else {
+					result.Failed++
+				}
 			} else {
-				failed++
-				fmt.Printf("❌ Failed (State: %s)\n", cb.GetState())
+				result.Successful++
 			}
-		} else {
-			successful++
-			fmt.Printf("✅ Success (State: %s)\n", cb.GetState())
+			if hooks.request != nil {
+				hooks.request(phase, request, err, cb.GetState())
+			}
+			sleepContext(ctx, pause)
+		}
+	}
+	startPhase := func(phase int) {
+		if hooks.phase != nil {
+			hooks.phase(phase)
 		}
-		time.Sleep(300 * time.Millisecond)
+	}
+
+	// Phase 1: Healthy service (CLOSED)
+	startPhase(1)
+	runPhase(1, phases.Healthy, healthy, phases.Pause)
+	if ctx.Err() != nil {
+		return result
+	}
+
+	// Phase 2: Service starts failing (CLOSED → OPEN)
+	startPhase(2)
+	runPhase(2, phases.Degrading, failing, phases.Pause)
+	if ctx.Err() != nil {
+		return result
 	}
 
 	// Phase 3: Wait and try recovery (OPEN → HALF_OPEN)
-	fmt.Println("\n⏰ Phase 3: Waiting for recovery window...")
-	time.Sleep(3100 * time.Millisecond)
+	startPhase(3)
+	if sleepContext(ctx, cb.openTimeout()+100*time.Millisecond) != nil {
+		return result
+	}
+	runPhase(3, phases.Recovery, recovering, phases.RecoveryPause)
 
-	for i := 11; i <= 15; i++ {
-		fmt.Printf("Request %d: ", i)
-		err := cb.Call(simulateRecoveringService)
-		if err != nil {
-			if err.Error() == "circuit breaker is OPEN" {
-				blocked++
-				fmt.Printf("🛑 BLOCKED (State: %s)\n", cb.GetState())
-			} else {
-				failed++
-				fmt.Printf("❌ Failed (State: %s)\n", cb.GetState())
+	return result
+}
+
+func runFullLifecycleDemo(ctx context.Context, cb *CircuitBreaker) LifecycleResult {
+	console.Println("🔄 === Full Circuit Breaker Lifecycle ===")
+	console.Println("Watch circuit breaker automatically handle service degradation and recovery")
+	console.Println()
+
+	hooks := lifecycleHooks{
+		phase: func(phase int) {
+			switch phase {
+			case 1:
+				console.Println("📡 Phase 1: Healthy service...")
+			case 2:
+				console.Println("\n💥 Phase 2: Service degrading...")
+			case 3:
+				console.Println("\n⏰ Phase 3: Waiting for recovery window...")
 			}
-		} else {
-			successful++
-			fmt.Printf("✅ Success! (State: %s)\n", cb.GetState())
-		}
-		time.Sleep(400 * time.Millisecond)
+		},
+		request: func(phase, request int, err error, state CircuitState) {
+			console.Detailf("Request %d: ", request)
+			switch {
+			case errors.Is(err, ErrOpenCircuit):
+				console.Detailf("🛑 BLOCKED (State: %s)\n", state)
+			case err != nil:
+				console.Detailf("❌ Failed (State: %s)\n", state)
+			case phase == 3:
+				console.Detailf("✅ Success! (State: %s)\n", state)
+			default:
+				console.Detailf("✅ Success (State: %s)\n", state)
+			}
+		},
+	}
+	result := runLifecycle(ctx, cb, DefaultLifecyclePhases(), hooks)
+	if ctx.Err() != nil {
+		return result
 	}
 
-	fmt.Printf("\n📊 Final Results: %d successful, %d failed, %d blocked\n", successful, failed, blocked)
-	fmt.Printf("🛡️  Circuit breaker prevented %d requests to failing service\n", blocked)
-	fmt.Printf("⚡ Automatic recovery detection enabled graceful service restoration\n")
+	console.Printf("\n📊 Final Results: %d successful, %d failed, %d blocked\n", result.Successful, result.Failed, result.Blocked)
+	console.Printf("🛡️  Circuit breaker prevented %d requests to failing service\n", result.Blocked)
+	console.Printf("⚡ Automatic recovery detection enabled graceful service restoration\n")
+	return result
 }
 
 func simulateHealthyService() error {
@@ -400,9 +885,21 @@ func simulateFailingService() error {
 }
 
 func simulateRecoveringService() error {
+	return recoveringServiceCall(rand.Float32)
+}
+
+// newRecoveringService returns a recovering service driven by rng, so runs
+// can be reproduced with a seeded generator.
+func newRecoveringService(rng *rand.Rand) func() error {
+	return func() error {
+		return recoveringServiceCall(rng.Float32)
+	}
+}
+
+func recoveringServiceCall(roll func() float32) error {
 	time.Sleep(75 * time.Millisecond)
 	// 70% chance of success during recovery
-	if rand.Float32() < 0.7 {
+	if roll() < 0.7 {
 		return nil
 	}
 	return fmt.Errorf("service still unstable")
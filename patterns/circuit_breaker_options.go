@@ -0,0 +1,117 @@
+package patterns
+
+import (
+	"math/rand"
+	"time"
+)
+
+// CBOption configures a CircuitBreaker created by NewCircuitBreakerOpts.
+type CBOption func(*CircuitBreaker)
+
+// NewCircuitBreakerOpts creates a breaker from functional options. Without
+// options it opens after 3 consecutive failures and probes again after 5s.
+func NewCircuitBreakerOpts(opts ...CBOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		state:            CLOSED,
+		failureThreshold: 3,
+		successThreshold: 1,
+		timeout:          5 * time.Second,
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	return cb
+}
+
+// WithFailureThreshold sets how many consecutive failures open the breaker.
+func WithFailureThreshold(threshold int) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.failureThreshold = threshold
+	}
+}
+
+// WithFailureRate switches the breaker to failure-rate mode: it opens when
+// the fraction of failures within window reaches ratio.
+func WithFailureRate(ratio float64, window time.Duration) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.failureRate = ratio
+		cb.window = window
+	}
+}
+
+// WithTimeout sets how long the breaker stays OPEN before allowing a probe.
+func WithTimeout(timeout time.Duration) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.timeout = timeout
+	}
+}
+
+// WithCallTimeout makes Call give up on fn after d, returning ErrCallTimeout
+// and counting it as a failure. fn is not interrupted, so it should return
+// promptly on its own to avoid piling up background goroutines.
+func WithCallTimeout(d time.Duration) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.callTimeout = d
+	}
+}
+
+// WithSuccessThreshold sets how many consecutive successful HALF_OPEN calls
+// are needed before the breaker closes. The default is 1.
+func WithSuccessThreshold(threshold int) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.successThreshold = threshold
+	}
+}
+
+// WithMinimumRequests is the option form of SetMinimumRequests.
+func WithMinimumRequests(n int) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.minimumRequests = n
+	}
+}
+
+// WithJitter is the option form of SetJitter.
+func WithJitter(jitter time.Duration) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.jitter = jitter
+	}
+}
+
+// WithProbeSampleRate admits each request arriving after the OPEN timeout as
+// the HALF_OPEN probe with probability rate (0 < rate < 1); the others are
+// rejected with ErrOpenCircuit as usual. The default admits the first one.
+func WithProbeSampleRate(rate float64) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.probeSampleRate = rate
+	}
+}
+
+// WithRandSource is the option form of SetRandSource.
+func WithRandSource(rng *rand.Rand) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.rng = rng
+	}
+}
+
+// WithPanicStack is the option form of SetCapturePanicStack.
+func WithPanicStack(enabled bool) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.capturePanicStack = enabled
+	}
+}
+
+// WithOnRecovery is the option form of SetOnRecovery.
+func WithOnRecovery(fn func()) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.onRecovery = fn
+	}
+}
+
+// WithOnStateChange registers fn to be called on every state transition.
+// Like the other hooks it runs after the breaker's lock is released.
+func WithOnStateChange(fn func(from, to CircuitState)) CBOption {
+	return func(cb *CircuitBreaker) {
+		cb.onStateChange = fn
+	}
+}
@@ -0,0 +1,48 @@
+package patterns
+
+import (
+	"sync"
+	"time"
+)
+
+// Registry hands out one CircuitBreaker per name (e.g. per downstream host),
+// creating breakers lazily with the registry's default settings.
+type Registry struct {
+	breakers  map[string]*CircuitBreaker
+	threshold int
+	timeout   time.Duration
+	mutex     sync.Mutex
+}
+
+func NewRegistry(threshold int, timeout time.Duration) *Registry {
+	return &Registry{
+		breakers:  make(map[string]*CircuitBreaker),
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+// Get returns the breaker registered under name, creating it on first use.
+func (r *Registry) Get(name string) *CircuitBreaker {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cb, ok := r.breakers[name]
+	if !ok {
+		cb = NewCircuitBreaker(r.threshold, r.timeout)
+		r.breakers[name] = cb
+	}
+	return cb
+}
+
+// Snapshot returns the current state of every registered breaker.
+func (r *Registry) Snapshot() map[string]CircuitState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	states := make(map[string]CircuitState, len(r.breakers))
+	for name, cb := range r.breakers {
+		states[name] = cb.GetState()
+	}
+	return states
+}
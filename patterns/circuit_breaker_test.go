@@ -0,0 +1,581 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errService = errors.New("service unavailable")
+
+// fakeClock is a time source the tests move forward by hand, installed with
+// setClock so OPEN timeouts can elapse without sleeping.
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestBreaker creates a breaker running on a fake clock.
+func newTestBreaker(opts ...CBOption) (*CircuitBreaker, *fakeClock) {
+	cb := NewCircuitBreakerOpts(opts...)
+	clock := newFakeClock()
+	cb.setClock(clock.Now)
+	return cb, clock
+}
+
+func succeed() error { return nil }
+func fail() error    { return errService }
+
+// cbStep is one call in a scripted breaker test: move the clock forward by
+// advance, call fn and check the error and resulting state.
+type cbStep struct {
+	advance time.Duration
+	fn      func() error
+	wantErr error
+	want    CircuitState
+}
+
+func runSteps(t *testing.T, cb *CircuitBreaker, clock *fakeClock, steps []cbStep) {
+	t.Helper()
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		err := cb.Call(step.fn)
+		if !errors.Is(err, step.wantErr) || (step.wantErr == nil && err != nil) {
+			t.Fatalf("step %d: Call = %v, want %v", i+1, err, step.wantErr)
+		}
+		if got := cb.GetState(); got != step.want {
+			t.Fatalf("step %d: state = %s, want %s", i+1, got, step.want)
+		}
+	}
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []CBOption
+		steps []cbStep
+	}{
+		{
+			name: "opens after threshold consecutive failures",
+			opts: []CBOption{WithFailureThreshold(3), WithTimeout(time.Minute)},
+			steps: []cbStep{
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, CLOSED},
+				{0, succeed, nil, CLOSED}, // resets the count
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, OPEN},
+				{time.Second, succeed, ErrOpenCircuit, OPEN},
+			},
+		},
+		{
+			name: "probe success closes",
+			opts: []CBOption{WithFailureThreshold(1), WithTimeout(time.Minute)},
+			steps: []cbStep{
+				{0, fail, errService, OPEN},
+				{59 * time.Second, succeed, ErrOpenCircuit, OPEN},
+				{2 * time.Second, succeed, nil, CLOSED},
+			},
+		},
+		{
+			name: "probe failure reopens",
+			opts: []CBOption{WithFailureThreshold(1), WithTimeout(time.Minute)},
+			steps: []cbStep{
+				{0, fail, errService, OPEN},
+				{61 * time.Second, fail, errService, OPEN},
+				{time.Second, succeed, ErrOpenCircuit, OPEN},
+				{61 * time.Second, succeed, nil, CLOSED},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, clock := newTestBreaker(tt.opts...)
+			runSteps(t, cb, clock, tt.steps)
+		})
+	}
+}
+
+func TestCircuitBreakerTimeoutBoundary(t *testing.T) {
+	cb, clock := newTestBreaker(WithFailureThreshold(1), WithTimeout(time.Minute))
+	runSteps(t, cb, clock, []cbStep{
+		{0, fail, errService, OPEN},
+		{time.Minute, succeed, ErrOpenCircuit, OPEN}, // exactly the timeout
+		{time.Nanosecond, succeed, nil, CLOSED},
+	})
+}
+
+func TestErrOpenCircuit(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+
+	if err := cb.Call(fail); errors.Is(err, ErrOpenCircuit) {
+		t.Errorf("the service's own error %v matched ErrOpenCircuit", err)
+	}
+	if err := cb.Call(succeed); !errors.Is(err, ErrOpenCircuit) {
+		t.Errorf("rejected Call = %v, want ErrOpenCircuit", err)
+	}
+}
+
+func TestCircuitBreakerMinimumRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []cbStep
+	}{
+		{
+			name: "failures below the minimum",
+			steps: []cbStep{
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, CLOSED},
+			},
+		},
+		{
+			name: "opens once the minimum is reached",
+			steps: []cbStep{
+				{0, succeed, nil, CLOSED},
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, CLOSED},
+				{0, fail, errService, OPEN},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, clock := newTestBreaker(WithFailureThreshold(3), WithMinimumRequests(4), WithTimeout(time.Minute))
+			runSteps(t, cb, clock, tt.steps)
+		})
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []CBOption
+		steps []cbStep
+	}{
+		{
+			name: "opens at exactly the ratio",
+			opts: []CBOption{WithFailureRate(0.5, time.Minute), WithMinimumRequests(4), WithTimeout(time.Minute)},
+			steps: []cbStep{
+				{0, fail, errService, CLOSED},
+				{0, succeed, nil, CLOSED},
+				{0, succeed, nil, CLOSED},
+				{0, fail, errService, OPEN}, // 2 of 4
+			},
+		},
+		{
+			name: "stays closed below the ratio",
+			opts: []CBOption{WithFailureRate(0.5, time.Minute), WithMinimumRequests(4), WithTimeout(time.Minute)},
+			steps: []cbStep{
+				{0, succeed, nil, CLOSED},
+				{0, succeed, nil, CLOSED},
+				{0, succeed, nil, CLOSED},
+				{0, fail, errService, CLOSED}, // 1 of 4
+			},
+		},
+		{
+			name: "window ages out",
+			opts: []CBOption{WithFailureRate(0.5, time.Minute), WithMinimumRequests(2), WithTimeout(time.Minute)},
+			steps: []cbStep{
+				{0, succeed, nil, CLOSED},
+				{0, succeed, nil, CLOSED},
+				{0, succeed, nil, CLOSED},
+				{2 * time.Minute, fail, errService, CLOSED}, // alone in the window
+				{0, succeed, nil, CLOSED},
+				{0, fail, errService, OPEN},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, clock := newTestBreaker(tt.opts...)
+			runSteps(t, cb, clock, tt.steps)
+		})
+	}
+}
+
+func TestNewCircuitBreakerRateValidates(t *testing.T) {
+	expectPanic(t, "NewCircuitBreakerRate with no minimum requests", func() {
+		NewCircuitBreakerRate(0.5, time.Minute, 0, time.Minute)
+	})
+
+	cb := NewCircuitBreakerRate(0.5, time.Minute, 10, time.Minute)
+	for i := 0; i < 9; i++ {
+		cb.Call(fail)
+	}
+	if cb.GetState() != CLOSED {
+		t.Errorf("opened after 9 calls, below the minimum of 10")
+	}
+	cb.Call(fail)
+	if cb.GetState() != OPEN {
+		t.Errorf("still %s after 10 failed calls", cb.GetState())
+	}
+}
+
+func TestCircuitBreakerPanics(t *testing.T) {
+	tests := []struct {
+		captureStack bool
+	}{{false}, {true}}
+	for _, tt := range tests {
+		cb := NewCircuitBreakerOpts(WithFailureThreshold(1), WithPanicStack(tt.captureStack))
+
+		err := cb.Call(func() error { panic("boom") })
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("Call = %v, want a *PanicError", err)
+		}
+		if panicErr.Value != "boom" {
+			t.Errorf("Value = %v, want boom", panicErr.Value)
+		}
+		if hasStack := len(panicErr.Stack) > 0; hasStack != tt.captureStack {
+			t.Errorf("stack captured = %v, want %v", hasStack, tt.captureStack)
+		}
+		if cb.GetState() != OPEN {
+			t.Errorf("a panic should count as a failure; state = %s", cb.GetState())
+		}
+	}
+}
+
+func TestCircuitBreakerMetrics(t *testing.T) {
+	cb, _ := newTestBreaker(WithFailureThreshold(3), WithTimeout(time.Minute), WithCallTimeout(10*time.Millisecond))
+
+	cb.Call(succeed)
+	cb.Call(fail)
+	cb.Call(func() error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	if err := cb.Call(fail); !errors.Is(err, errService) {
+		t.Fatalf("Call = %v", err)
+	}
+	if err := cb.Call(succeed); !errors.Is(err, ErrOpenCircuit) {
+		t.Fatalf("Call on an open breaker = %v, want ErrOpenCircuit", err)
+	}
+
+	want := CircuitBreakerMetrics{
+		TotalCalls:      5,
+		TotalSuccesses:  1,
+		TotalErrors:     2,
+		TotalTimeouts:   1,
+		TotalRejections: 1,
+	}
+	if got := cb.Metrics(); got != want {
+		t.Errorf("Metrics = %+v, want %+v", got, want)
+	}
+
+	cb.Reset()
+	if got := cb.Metrics(); got != (CircuitBreakerMetrics{}) || cb.GetState() != CLOSED {
+		t.Errorf("after Reset: state %s, metrics %+v", cb.GetState(), got)
+	}
+}
+
+func TestCircuitBreakerJitter(t *testing.T) {
+	const timeout, jitter, trips = time.Minute, 10 * time.Second, 8
+
+	plain := NewCircuitBreaker(1, timeout)
+	plain.Call(fail)
+	if got := plain.openTimeout(); got != timeout {
+		t.Errorf("open timeout without jitter = %v, want %v", got, timeout)
+	}
+
+	cb, clock := newTestBreaker(
+		WithFailureThreshold(1),
+		WithTimeout(timeout),
+		WithJitter(jitter),
+		WithRandSource(rand.New(rand.NewSource(3))),
+	)
+	seen := make(map[time.Duration]bool)
+	for i := range trips {
+		cb.Call(fail)
+		open := cb.openTimeout()
+		if open < timeout || open >= timeout+jitter {
+			t.Errorf("trip %d: open timeout %v, want within [%v, %v)", i+1, open, timeout, timeout+jitter)
+		}
+		seen[open] = true
+
+		// The probe is admitted only once the jittered timeout has passed
+		runSteps(t, cb, clock, []cbStep{
+			{open, succeed, ErrOpenCircuit, OPEN},
+			{time.Millisecond, succeed, nil, CLOSED},
+		})
+	}
+	if len(seen) == 1 {
+		t.Errorf("all %d trips stayed open for the same %v", trips, cb.openTimeout())
+	}
+}
+
+func TestCircuitBreakerProbeSampling(t *testing.T) {
+	const seed, rate = 11, 0.3
+	rolls := rand.New(rand.NewSource(seed))
+	rejected := 0
+	for rolls.Float64() >= rate {
+		rejected++
+	}
+
+	cb, clock := newTestBreaker(
+		WithFailureThreshold(1),
+		WithTimeout(time.Minute),
+		WithProbeSampleRate(rate),
+		WithRandSource(rand.New(rand.NewSource(seed))),
+	)
+	cb.Call(fail)
+	clock.Advance(2 * time.Minute)
+
+	for i := 0; i < rejected; i++ {
+		if err := cb.Call(succeed); !errors.Is(err, ErrOpenCircuit) {
+			t.Fatalf("request %d: Call = %v, want it rejected by sampling", i+1, err)
+		}
+	}
+	if err := cb.Call(succeed); err != nil {
+		t.Fatalf("request %d: Call = %v, want it admitted as the probe", rejected+1, err)
+	}
+	if cb.GetState() != CLOSED {
+		t.Errorf("state = %s after the probe succeeded", cb.GetState())
+	}
+
+	sampler := NewCircuitBreakerOpts(WithProbeSampleRate(rate), WithRandSource(rand.New(rand.NewSource(seed))))
+	admitted := 0
+	for range 1000 {
+		if sampler.admitProbe() {
+			admitted++
+		}
+	}
+	if got := float64(admitted) / 1000; math.Abs(got-rate) > 0.05 {
+		t.Errorf("admitted %.2f of requests as probes, want about %v", got, rate)
+	}
+}
+
+func TestNewCircuitBreakerOpts(t *testing.T) {
+	var transitions [][2]CircuitState
+	cb, clock := newTestBreaker(
+		WithFailureThreshold(2),
+		WithTimeout(time.Minute),
+		WithSuccessThreshold(2),
+		WithOnStateChange(func(from, to CircuitState) {
+			transitions = append(transitions, [2]CircuitState{from, to})
+		}),
+	)
+
+	runSteps(t, cb, clock, []cbStep{
+		{0, fail, errService, CLOSED},
+		{0, fail, errService, OPEN},
+		{59 * time.Second, succeed, ErrOpenCircuit, OPEN},
+		{2 * time.Second, succeed, nil, HALF_OPEN},
+		{0, succeed, nil, CLOSED},
+	})
+
+	want := [][2]CircuitState{{CLOSED, OPEN}, {OPEN, HALF_OPEN}, {HALF_OPEN, CLOSED}}
+	if !slices.Equal(transitions, want) {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+}
+
+func TestCircuitBreakerOnRecovery(t *testing.T) {
+	var recoveries int
+	var cb *CircuitBreaker
+	cb, clock := newTestBreaker(
+		WithFailureThreshold(1),
+		WithTimeout(time.Minute),
+		WithOnRecovery(func() {
+			recoveries++
+			cb.GetState() // hooks run without the lock held
+		}),
+	)
+
+	runSteps(t, cb, clock, []cbStep{
+		{0, succeed, nil, CLOSED},
+		{0, fail, errService, OPEN},
+		{2 * time.Minute, succeed, nil, CLOSED},
+		{0, succeed, nil, CLOSED},
+	})
+	if recoveries != 1 {
+		t.Errorf("OnRecovery called %d times, want 1", recoveries)
+	}
+
+	cb.SetOnRecovery(nil)
+	cb.Call(fail)
+	clock.Advance(2 * time.Minute)
+	cb.Call(succeed)
+	if recoveries != 1 {
+		t.Errorf("OnRecovery called after it was cleared")
+	}
+}
+
+func TestCircuitBreakerLongestOutage(t *testing.T) {
+	cb, clock := newTestBreaker(WithFailureThreshold(1), WithTimeout(time.Minute))
+
+	runSteps(t, cb, clock, []cbStep{
+		{0, fail, errService, OPEN},
+		{61 * time.Second, fail, errService, OPEN}, // failed probe, same outage
+		{61 * time.Second, succeed, nil, CLOSED},
+	})
+	if got := cb.LongestOutage(); got != 122*time.Second {
+		t.Errorf("LongestOutage = %v, want 2m2s", got)
+	}
+
+	runSteps(t, cb, clock, []cbStep{
+		{0, fail, errService, OPEN},
+		{61 * time.Second, succeed, nil, CLOSED},
+	})
+	if got := cb.LongestOutage(); got != 122*time.Second {
+		t.Errorf("LongestOutage = %v after a shorter outage, want 2m2s", got)
+	}
+
+	cb.Call(fail)
+	clock.Advance(5 * time.Minute)
+	if got := cb.LongestOutage(); got != 5*time.Minute {
+		t.Errorf("LongestOutage = %v during a 5m outage, want 5m", got)
+	}
+
+	cb.Reset()
+	if got := cb.LongestOutage(); got != 0 {
+		t.Errorf("LongestOutage = %v after Reset, want 0", got)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(1, time.Minute)
+
+	payments := r.Get("payments")
+	if r.Get("payments") != payments {
+		t.Fatal("Get returned a different breaker for the same name")
+	}
+	search := r.Get("search")
+	if search == payments {
+		t.Fatal("Get returned the same breaker for different names")
+	}
+
+	payments.Call(fail)
+	snapshot := r.Snapshot()
+	want := map[string]CircuitState{"payments": OPEN, "search": CLOSED}
+	if len(snapshot) != len(want) {
+		t.Fatalf("Snapshot = %v, want %v", snapshot, want)
+	}
+	for name, state := range want {
+		if snapshot[name] != state {
+			t.Errorf("Snapshot[%q] = %s, want %s", name, snapshot[name], state)
+		}
+	}
+}
+
+func TestRegistryConcurrentGet(t *testing.T) {
+	r := NewRegistry(3, time.Minute)
+
+	breakers := make([]*CircuitBreaker, 20)
+	var wg sync.WaitGroup
+	for i := range breakers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			breakers[i] = r.Get("shared")
+			r.Get("service-" + strconv.Itoa(i))
+		}()
+	}
+	wg.Wait()
+
+	for _, cb := range breakers {
+		if cb != breakers[0] {
+			t.Fatal("concurrent Get calls created more than one breaker")
+		}
+	}
+	if got := len(r.Snapshot()); got != len(breakers)+1 {
+		t.Errorf("registry holds %d breakers, want %d", got, len(breakers)+1)
+	}
+}
+
+func TestTripBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		cb   *CircuitBreaker
+		want CircuitState
+	}{
+		{"consecutive failures", NewCircuitBreaker(3, time.Minute), OPEN},
+		{"failure rate", NewCircuitBreakerRate(0.5, time.Minute, 10, time.Minute), OPEN},
+		{"never opens", NewCircuitBreaker(maxTripCalls+1, time.Minute), CLOSED},
+	}
+	for _, tt := range tests {
+		tripBreaker(tt.cb, "down")
+		if got := tt.cb.GetState(); got != tt.want {
+			t.Errorf("%s: state after tripBreaker = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunLifecycleSeeded(t *testing.T) {
+	const seed, threshold, recovery = 42, 3, 6
+
+	// Replay the seeded service's rolls to work out the recovery phase: the
+	// first call is the probe, a failed probe reopens the circuit and, with
+	// no pause between requests, the rest of the phase is then blocked.
+	rolls := rand.New(rand.NewSource(seed))
+	var want LifecycleResult
+	want.Successful, want.Failed, want.Blocked = 3, threshold, 5-threshold
+	state, failures := HALF_OPEN, 0
+	for i := 0; i < recovery; i++ {
+		if state == OPEN {
+			want.Blocked++
+			continue
+		}
+		if rolls.Float32() < 0.7 {
+			want.Successful++
+			state, failures = CLOSED, 0
+			continue
+		}
+		want.Failed++
+		failures++
+		if state == HALF_OPEN || failures >= threshold {
+			state = OPEN
+		}
+	}
+
+	for run := 0; run < 2; run++ {
+		cb := NewCircuitBreaker(threshold, 50*time.Millisecond)
+		got := RunLifecycle(cb, LifecyclePhases{
+			Healthy:           3,
+			Degrading:         5,
+			Recovery:          recovery,
+			HealthyService:    succeed,
+			FailingService:    fail,
+			RecoveringService: newRecoveringService(rand.New(rand.NewSource(seed))),
+		})
+		if got != want {
+			t.Errorf("run %d: RunLifecycle = %+v, want %+v", run+1, got, want)
+		}
+	}
+}
+
+func TestCircuitBreakerLifecycleCancelled(t *testing.T) {
+	quietOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := CircuitBreakerLifecycle(ctx)
+	if !summary.Cancelled {
+		t.Error("summary not marked as cancelled")
+	}
+	if summary.Completed != 0 {
+		t.Errorf("completed %d requests after cancellation", summary.Completed)
+	}
+}
@@ -1,119 +1,467 @@
 package patterns
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"math/rand"
+	"slices"
 	"sync"
 	"time"
 )
 
-func FanOutFanIn() {
-	fmt.Println("=== Fan-out/Fan-in Pattern ===")
-	fmt.Println("Distributing work to multiple goroutines, then collecting results")
-	fmt.Println()
+// FanOutFanIn asks for a worker count and runs the fan-out/fan-in demos.
+// Cancelling ctx cuts the comparison short and skips the failing items demo.
+func FanOutFanIn(ctx context.Context) {
+	fanOutFanInHeader()
+	numWorkers := promptInt("Number of workers", 3, 1)
+	console.Println()
+
+	if summary := runFanOutFanInComparison(ctx, numWorkers); summary.Cancelled {
+		return
+	}
+	runFanOutErrorsDemo(ctx, numWorkers)
+}
+
+// FanOutFanInComparison runs the fan-out/fan-in comparison with 3 workers,
+// without asking for input. Cancelling ctx cuts it short.
+func FanOutFanInComparison(ctx context.Context) RunSummary {
+	fanOutFanInHeader()
+	return runFanOutFanInComparison(ctx, 3)
+}
+
+func fanOutFanInHeader() {
+	console.Println("=== Fan-out/Fan-in Pattern ===")
+	console.Println("Distributing work to multiple goroutines, then collecting results")
+	console.Println()
+}
+
+func runFanOutFanInComparison(ctx context.Context, numWorkers int) RunSummary {
+	summary := RunSummary{Pattern: "fan-out-fan-in", Workers: numWorkers}
 
 	// Run concurrent version
-	fmt.Println("Running CONCURRENT version...")
-	concurrentStart := time.Now()
-	runFanOutFanInConcurrent()
-	concurrentDuration := time.Since(concurrentStart)
+	console.Detailln("Running CONCURRENT version...")
+	results, concurrentDuration := runFanOutFanInConcurrent(ctx, numWorkers)
+	summary.Completed, summary.Concurrent = len(results), concurrentDuration
+	if cancelled(ctx, &summary) {
+		return summary
+	}
+	console.Printf("Processed %d numbers with %d workers\n", len(results), numWorkers)
+	console.Detailf("Results (in completion order): %v\n", results)
 
-	fmt.Printf("\nCONCURRENT version took: %v\n\n", concurrentDuration)
+	console.Printf("\nCONCURRENT version took: %v\n\n", concurrentDuration)
 
 	// Run sequential version for comparison
-	fmt.Println("Running SEQUENTIAL version for comparison...")
-	sequentialStart := time.Now()
-	runFanOutFanInSequential()
-	sequentialDuration := time.Since(sequentialStart)
+	console.Detailln("Running SEQUENTIAL version for comparison...")
+	sequentialResults, sequentialDuration := runFanOutFanInSequential(ctx)
+	summary.Sequential = sequentialDuration
+	if cancelled(ctx, &summary) {
+		return summary
+	}
+	console.Printf("Processed %d numbers sequentially\n", len(sequentialResults))
+
+	match := sameResults(results, sequentialResults)
+	if match {
+		console.Println("✅ Both versions produced the same squares")
+	} else {
+		console.Println("❌ Concurrent and sequential results differ!")
+	}
+	summary.Details = map[string]any{"resultsMatch": match}
 
-	fmt.Printf("\nSEQUENTIAL version took: %v\n", sequentialDuration)
-	fmt.Printf("Speedup: %.2fx faster with concurrency!\n\n", float64(sequentialDuration)/float64(concurrentDuration))
+	console.Printf("\nSEQUENTIAL version took: %v\n", sequentialDuration)
+	console.Printf("Speedup: %.2fx faster with concurrency!\n\n", summary.Speedup())
+	return summary
 }
 
-func runFanOutFanInConcurrent() {
-	
-	// Input data
+// runFanOutFanInConcurrent squares 1..10 with numWorkers workers and returns
+// the squares in completion order along with how long it took. If ctx is
+// cancelled it returns the squares finished so far.
+func runFanOutFanInConcurrent(ctx context.Context, numWorkers int) ([]int, time.Duration) {
 	numbers := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
-	
+	start := time.Now()
+
+	var results []int
+	for result := range fanOutFanIn(ctx, numbers, numWorkers) {
+		results = append(results, result)
+	}
+	return results, time.Since(start)
+}
+
+// FanOutFanInWith squares every number using the given number of workers, all
+// competing for the same input channel, and returns the squares in the order
+// they were finished. That order depends on scheduling, so only the set of
+// results is stable across worker counts.
+func FanOutFanInWith(numbers []int, workers int) []int {
+	results := make([]int, 0, len(numbers))
+	for result := range fanOutFanIn(context.Background(), numbers, workers) {
+		results = append(results, result)
+	}
+	return results
+}
+
+// fanOutFanIn starts the workers and returns the merged stream of squares.
+// Cancelling ctx stops the feeder, the workers and the merge, so a caller may
+// stop reading early without leaking any of them.
+func fanOutFanIn(ctx context.Context, numbers []int, workers int) <-chan int {
 	// Fan-out: distribute work
 	input := make(chan int)
-	
+
 	// Start multiple workers (fan-out)
-	const numWorkers = 3
-	var outputs []<-chan int
-	
-	for i := 0; i < numWorkers; i++ {
-		output := make(chan int)
-		outputs = append(outputs, output)
-		go fanOutWorker(i+1, input, output)
-	}
-	
+	outputs := FanOutContext(ctx, workers, input, slowSquare)
+
 	// Send input data
 	go func() {
 		defer close(input)
 		for _, num := range numbers {
-			input <- num
+			select {
+			case input <- num:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
-	
+
 	// Fan-in: collect results from all workers
-	results := fanIn(outputs...)
-	
-	// Count processed results
-	var processed int
-	for range results {
-		processed++
+	return FanInContext(ctx, outputs...)
+}
+
+// runFanOutErrorsDemo fans a fallible job out to numWorkers workers and
+// merges successes and failures back into a single stream.
+func runFanOutErrorsDemo(ctx context.Context, numWorkers int) {
+	console.Println("⚠️  === Failing Items ===")
+	console.Println("Every fourth number is rejected; the errors are merged in with the squares")
+	console.Println()
+
+	input := make(chan int)
+	go func() {
+		defer close(input)
+		for num := 1; num <= 10; num++ {
+			select {
+			case input <- num:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	outputs := FanOutTry(ctx, numWorkers, input, func(num int) (int, error) {
+		if num%4 == 0 {
+			return 0, errors.New("rejected by upstream service")
+		}
+		return slowSquare(num), nil
+	})
+
+	var succeeded, failed int
+	for result := range FanInResults(outputs...) {
+		if result.Err != nil {
+			failed++
+			console.Detailf("❌ %v\n", result.Err)
+			continue
+		}
+		succeeded++
+		console.Detailf("✅ %d\n", result.Value)
 	}
-	
-	fmt.Printf("Processed %d numbers with %d workers\n", processed, numWorkers)
+
+	console.Printf("\n📊 Results: %d succeeded, %d failed\n\n", succeeded, failed)
 }
 
-func runFanOutFanInSequential() {
+// runFanOutFanInSequential squares 1..10 one at a time with the same payload
+// as the concurrent version and returns the squares along with how long it
+// took. If ctx is cancelled it stops after the current number.
+func runFanOutFanInSequential(ctx context.Context) ([]int, time.Duration) {
 	numbers := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
-	
+	start := time.Now()
+
+	results := make([]int, 0, len(numbers))
 	for _, num := range numbers {
-		// Simulate processing with same average delay as concurrent version
-		processingTime := time.Duration(rand.Intn(200)+50) * time.Millisecond
-		time.Sleep(processingTime)
-		
-		_ = num * num // Square the number
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, slowSquare(num))
+	}
+
+	return results, time.Since(start)
+}
+
+// sameResults reports whether a and b hold the same values, ignoring order.
+func sameResults(a, b []int) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
+
+// slowSquare is the demo payload: it squares num after a random 50-250ms
+// delay standing in for real work.
+func slowSquare(num int) int {
+	// Simulate processing with random delay
+	processingTime := time.Duration(rand.Intn(200)+50) * time.Millisecond
+	time.Sleep(processingTime)
+
+	return num * num // Square the number
+}
+
+// FanOut starts workers goroutines that all compete for values from in, apply
+// fn and send the result on their own output channel. Each output is closed
+// once in is exhausted, so the slice can be handed straight to FanIn.
+func FanOut[I, O any](workers int, in <-chan I, fn func(I) O) []<-chan O {
+	return FanOutContext(context.Background(), workers, in, fn)
+}
+
+// FanOutContext is FanOut with cancellation: once ctx is done each worker
+// stops after its current call to fn and closes its output, even if nobody
+// is reading.
+func FanOutContext[I, O any](ctx context.Context, workers int, in <-chan I, fn func(I) O) []<-chan O {
+	if workers < 1 {
+		panic(fmt.Sprintf("patterns: fan-out needs at least 1 worker, got %d", workers))
+	}
+
+	outputs := make([]<-chan O, workers)
+	for i := range outputs {
+		output := make(chan O)
+		outputs[i] = output
+		go fanOutWorker(ctx, in, output, fn)
+	}
+	return outputs
+}
+
+func fanOutWorker[I, O any](ctx context.Context, input <-chan I, output chan<- O, fn func(I) O) {
+	defer close(output)
+	for val := range input {
+		select {
+		case output <- fn(val):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Distribute deals the values from in round-robin onto n dedicated channels,
+// closing all of them once in is exhausted. Unlike the shared input channel
+// used by FanOut, where idle workers steal whatever comes next, each value
+// goes to a fixed channel in turn: the load is evenly split by count and a
+// consumer always sees the same slice of the input. The price is that one
+// slow consumer holds up the others, since the next value cannot be dealt
+// until the current one is taken.
+func Distribute[T any](in <-chan T, n int) []<-chan T {
+	if n < 1 {
+		panic(fmt.Sprintf("patterns: Distribute needs at least 1 output, got %d", n))
+	}
+
+	outs := make([]chan T, n)
+	result := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		result[i] = outs[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+
+		next := 0
+		for val := range in {
+			outs[next] <- val
+			next = (next + 1) % n
+		}
+	}()
+
+	return result
+}
+
+// Result is a worker's outcome for one input: either the computed Value or
+// the Err that prevented it.
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// FanOutTry is FanOutContext for a fn that can fail. Each outcome is sent as
+// a Result, with errors wrapped to name the worker and the input; a failed
+// item does not stop its worker, which moves on to the next input. Merge
+// the outputs with FanInResults.
+func FanOutTry[I, O any](ctx context.Context, workers int, in <-chan I, fn func(I) (O, error)) []<-chan Result[O] {
+	if workers < 1 {
+		panic(fmt.Sprintf("patterns: fan-out needs at least 1 worker, got %d", workers))
+	}
+
+	outputs := make([]<-chan Result[O], workers)
+	for i := range outputs {
+		output := make(chan Result[O])
+		outputs[i] = output
+		go fanOutTryWorker(ctx, i+1, in, output, fn)
 	}
-	
-	fmt.Printf("Processed %d numbers sequentially\n", len(numbers))
+	return outputs
 }
 
-func fanOutWorker(id int, input <-chan int, output chan<- int) {
+func fanOutTryWorker[I, O any](ctx context.Context, id int, input <-chan I, output chan<- Result[O], fn func(I) (O, error)) {
 	defer close(output)
-	for num := range input {
-		// Simulate processing with random delay
-		processingTime := time.Duration(rand.Intn(200)+50) * time.Millisecond
-		time.Sleep(processingTime)
-		
-		result := num * num // Square the number
-		output <- result
+	for val := range input {
+		value, err := fn(val)
+		if err != nil {
+			err = fmt.Errorf("worker %d: item %v: %w", id, val, err)
+		}
+		select {
+		case output <- Result[O]{Value: value, Err: err}:
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-func fanIn(inputs ...<-chan int) <-chan int {
+// FanInResults merges the Result streams of several workers into one, so the
+// collector sees failures alongside successes. Like FanIn, the output closes
+// once every input has closed.
+func FanInResults[T any](inputs ...<-chan Result[T]) <-chan Result[T] {
+	return FanIn(inputs...)
+}
+
+// FanIn merges values from all inputs onto a single channel, closing it only
+// after every input has been closed. Values from different inputs interleave
+// in whatever order they arrive. The caller must drain the output; use
+// FanInContext to be able to walk away early.
+func FanIn[T any](inputs ...<-chan T) <-chan T {
+	return FanInContext(context.Background(), inputs...)
+}
+
+// FanInContext is FanIn with cancellation: once ctx is done the forwarding
+// goroutines stop sending and the output is closed, even if inputs are still
+// open or nobody is reading.
+func FanInContext[T any](ctx context.Context, inputs ...<-chan T) <-chan T {
+	return fanIn(ctx, 0, inputs...)
+}
+
+// FanInBuffered is FanIn with room for buf values on the merged channel, so
+// workers can keep producing while a slow consumer is busy with earlier
+// results instead of waiting on every hand-off. Bursts up to buf are
+// absorbed; a consumer that is slower on average still sets the pace.
+func FanInBuffered[T any](buf int, inputs ...<-chan T) <-chan T {
+	return fanIn(context.Background(), buf, inputs...)
+}
+
+func fanIn[T any](ctx context.Context, buf int, inputs ...<-chan T) <-chan T {
 	var wg sync.WaitGroup
-	output := make(chan int)
-	
+	output := make(chan T, buf)
+
 	// Start a goroutine for each input channel
 	for _, input := range inputs {
 		wg.Add(1)
-		go func(ch <-chan int) {
+		go func(ch <-chan T) {
 			defer wg.Done()
-			for val := range ch {
-				output <- val
+			for {
+				var val T
+				var ok bool
+				select {
+				case val, ok = <-ch:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+
+				select {
+				case output <- val:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}(input)
 	}
-	
+
 	// Close output channel when all input channels are done
 	go func() {
 		wg.Wait()
 		close(output)
 	}()
-	
+
+	return output
+}
+
+// Indexed pairs a value with its position in the original input, so results
+// that fan back in out of order can be put back in sequence.
+type Indexed[T any] struct {
+	Index int
+	Value T
+}
+
+// OrderedFanIn merges inputs like FanIn but emits values in Index order,
+// starting from 0. A value that arrives early is held until every lower index
+// has been emitted. If the inputs close with gaps in the sequence, the held
+// values are flushed in index order.
+func OrderedFanIn[T any](inputs ...<-chan Indexed[T]) <-chan T {
+	output := make(chan T)
+
+	go func() {
+		defer close(output)
+		pending := make(map[int]T)
+		next := 0
+		for item := range FanIn(inputs...) {
+			pending[item.Index] = item.Value
+			for {
+				val, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				output <- val
+				next++
+			}
+		}
+
+		// Inputs closed with gaps: flush what is left in order
+		for _, index := range slices.Sorted(maps.Keys(pending)) {
+			output <- pending[index]
+		}
+	}()
+
+	return output
+}
+
+// Tagged is a value from one of several differently typed streams, labelled
+// with the Kind of stream it came from so a single consumer can switch on it.
+type Tagged struct {
+	Kind    string
+	Payload any
+}
+
+// MergeTagged fans in streams of different kinds, keyed by kind, labelling
+// each value with the key of the channel it arrived on. As with FanIn the
+// output closes once every input has closed, and values from different kinds
+// interleave in arrival order.
+func MergeTagged(chans map[string]<-chan any) <-chan Tagged {
+	var wg sync.WaitGroup
+	output := make(chan Tagged)
+
+	for kind, ch := range chans {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for val := range ch {
+				output <- Tagged{Kind: kind, Payload: val}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(output)
+	}()
+
 	return output
-}
\ No newline at end of file
+}
+
+// First returns the first value produced by any of chans, for racing
+// redundant requests and keeping only the fastest answer. Once a value is
+// chosen the others are abandoned: the goroutines watching the remaining
+// channels exit straight away rather than waiting for them to produce or
+// close. ok is false if ctx is done, or every channel closes, before any
+// value arrives.
+func First[T any](ctx context.Context, chans ...<-chan T) (value T, ok bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	value, ok = <-FanInContext(ctx, chans...)
+	return value, ok
+}
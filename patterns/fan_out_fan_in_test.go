@@ -0,0 +1,390 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFanOutWorkFunction(t *testing.T) {
+	tests := []struct {
+		workers int
+		in      []string
+	}{
+		{1, []string{"a", "bb", "ccc"}},
+		{3, []string{"a", "bb", "ccc", "dddd", "eeeee"}},
+		{8, []string{"xy"}},
+	}
+	for _, tt := range tests {
+		got := drain(FanIn(FanOut(tt.workers, sliceChan(tt.in...), func(s string) int { return len(s) })...))
+		slices.Sort(got)
+
+		var want []int
+		for _, s := range tt.in {
+			want = append(want, len(s))
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%d workers: got %v, want %v", tt.workers, got, want)
+		}
+	}
+}
+
+func TestFanOutWorkerCounts(t *testing.T) {
+	var numbers, want []int
+	for n := 1; n <= 20; n++ {
+		numbers = append(numbers, n)
+		want = append(want, n*n)
+	}
+	for _, workers := range []int{1, 3, 10} {
+		got := drain(FanIn(FanOut(workers, sliceChan(numbers...), square)...))
+		if !sameResults(got, want) {
+			t.Errorf("%d workers: got %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestFanOutFanInMatchesSequential(t *testing.T) {
+	concurrent, _ := runFanOutFanInConcurrent(context.Background(), 3)
+	sequential, _ := runFanOutFanInSequential(context.Background())
+	if !sameResults(concurrent, sequential) || len(sequential) != 10 {
+		t.Errorf("concurrent results %v, sequential %v; want the same 10 squares", concurrent, sequential)
+	}
+}
+
+func TestFanOutFanInWith(t *testing.T) {
+	numbers := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, workers := range []int{3, 10} {
+		got := FanOutFanInWith(numbers, workers)
+		if !sameResults(got, []int{1, 4, 9, 16, 25, 36, 49, 64, 81, 100}) {
+			t.Errorf("%d workers: got %v", workers, got)
+		}
+	}
+}
+
+func TestFanOutValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"FanOut", func() { FanOut(0, sliceChan(1), square) }},
+		{"FanOutTry", func() {
+			FanOutTry(context.Background(), 0, sliceChan(1), func(n int) (int, error) { return n, nil })
+		}},
+		{"Distribute", func() { Distribute(sliceChan(1), 0) }},
+	}
+	for _, tt := range tests {
+		expectPanic(t, tt.name+" with no workers", tt.fn)
+	}
+}
+
+func TestSameResults(t *testing.T) {
+	tests := []struct {
+		a, b []int
+		want bool
+	}{
+		{[]int{1, 4, 9}, []int{9, 1, 4}, true},
+		{[]int{1, 4}, []int{1, 4, 9}, false},
+		{[]int{1, 1, 4}, []int{1, 4, 4}, false},
+		{nil, []int{}, true},
+	}
+	for _, tt := range tests {
+		if got := sameResults(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameResults(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFanInGeneric(t *testing.T) {
+	words := drain(FanIn(sliceChan("a", "b"), sliceChan("c"), sliceChan[string]()))
+	slices.Sort(words)
+	if !slices.Equal(words, []string{"a", "b", "c"}) {
+		t.Errorf("merged strings %v, want [a b c]", words)
+	}
+
+	type point struct{ x, y int }
+	points := drain(FanIn(sliceChan(point{1, 2}, point{3, 4}), sliceChan(point{5, 6})))
+	for _, want := range []point{{1, 2}, {3, 4}, {5, 6}} {
+		if n := slices.Index(points, want); n < 0 || slices.Contains(points[n+1:], want) {
+			t.Errorf("merged structs %v, want %v exactly once", points, want)
+		}
+	}
+	if len(points) != 3 {
+		t.Errorf("merged %d structs, want 3", len(points))
+	}
+}
+
+func TestFanInKeepsPerInputOrder(t *testing.T) {
+	odds := sliceChan(1, 3, 5, 7, 9)
+	evens := sliceChan(2, 4, 6, 8, 10)
+
+	var gotOdds, gotEvens []int
+	for v := range FanIn(odds, evens) {
+		if v%2 == 1 {
+			gotOdds = append(gotOdds, v)
+		} else {
+			gotEvens = append(gotEvens, v)
+		}
+	}
+	if !slices.Equal(gotOdds, []int{1, 3, 5, 7, 9}) || !slices.Equal(gotEvens, []int{2, 4, 6, 8, 10}) {
+		t.Errorf("values from one input arrived out of order: %v, %v", gotOdds, gotEvens)
+	}
+}
+
+func TestFanInBuffered(t *testing.T) {
+	for _, buf := range []int{0, 4} {
+		out := FanInBuffered(buf, sliceChan(1, 2), sliceChan(3))
+		if cap(out) != buf {
+			t.Errorf("buf %d: capacity %d", buf, cap(out))
+		}
+		got := drain(out)
+		slices.Sort(got)
+		if !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("buf %d: got %v", buf, got)
+		}
+	}
+}
+
+func TestFanInBufferedOverlapsSlowConsumer(t *testing.T) {
+	// A producer that is fast for the first half of its items and slow for
+	// the rest, read by a consumer that is slow for the first half and fast
+	// for the rest. Only a buffer lets the two slow halves overlap.
+	const items, slow = 8, 10 * time.Millisecond
+	run := func(buf int) time.Duration {
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			for i := range 2 * items {
+				if i >= items {
+					time.Sleep(slow)
+				}
+				in <- i
+			}
+		}()
+
+		start := time.Now()
+		for n := range FanInBuffered(buf, in) {
+			if n < items {
+				time.Sleep(slow)
+			}
+		}
+		return time.Since(start)
+	}
+
+	unbuffered, buffered := run(0), run(8)
+	if buffered > unbuffered*3/4 {
+		t.Errorf("buffer 8 took %v, buffer 0 took %v; want the buffer to save time", buffered, unbuffered)
+	}
+}
+
+func TestFanInContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	open := make(chan int) // never closed or written to
+	out := FanInContext(ctx, open, sliceChan(1, 2, 3))
+
+	<-out
+	cancel()
+	closesWithin(t, "FanInContext output after cancel", out)
+}
+
+func TestFanOutContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		in <- i
+	}
+	close(in)
+
+	outputs := FanOutContext(ctx, 3, in, square)
+	cancel() // nobody has read anything yet
+	for i, out := range outputs {
+		closesWithin(t, fmt.Sprintf("worker %d output", i+1), out)
+	}
+}
+
+func TestFanOutFanInCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := fanOutFanIn(ctx, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 2)
+	<-out
+	cancel()
+	closesWithin(t, "fanOutFanIn output after cancel", out)
+}
+
+func TestOrderedFanIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs [][]Indexed[string]
+		want   []string
+	}{
+		{
+			name: "reordered across inputs",
+			inputs: [][]Indexed[string]{
+				{{3, "d"}, {0, "a"}},
+				{{2, "c"}, {1, "b"}, {4, "e"}},
+			},
+			want: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "gaps are flushed in order",
+			inputs: [][]Indexed[string]{
+				{{5, "f"}, {0, "a"}},
+				{{3, "d"}},
+			},
+			want: []string{"a", "d", "f"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chans []<-chan Indexed[string]
+			for _, in := range tt.inputs {
+				chans = append(chans, sliceChan(in...))
+			}
+			if got := drain(OrderedFanIn(chans...)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	outs := Distribute(sliceChan(0, 1, 2, 3, 4, 5, 6), 3)
+
+	got := make([][]int, len(outs))
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i] = drain(out)
+		}()
+	}
+	wg.Wait()
+
+	want := [][]int{{0, 3, 6}, {1, 4}, {2, 5}}
+	for i := range want {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("output %d got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMergeTagged(t *testing.T) {
+	chans := map[string]<-chan any{
+		"int":    sliceChan[any](1, 2),
+		"string": sliceChan[any]("a"),
+		"empty":  sliceChan[any](),
+	}
+
+	counts := make(map[string]int)
+	for tagged := range MergeTagged(chans) {
+		counts[tagged.Kind]++
+		switch tagged.Kind {
+		case "int":
+			if _, ok := tagged.Payload.(int); !ok {
+				t.Errorf("int stream carried %T", tagged.Payload)
+			}
+		case "string":
+			if _, ok := tagged.Payload.(string); !ok {
+				t.Errorf("string stream carried %T", tagged.Payload)
+			}
+		}
+	}
+	if counts["int"] != 2 || counts["string"] != 1 || counts["empty"] != 0 {
+		t.Errorf("counts = %v", counts)
+	}
+}
+
+func TestFirst(t *testing.T) {
+	delayed := func(v int, d time.Duration) <-chan int {
+		ch := make(chan int)
+		go func() {
+			time.Sleep(d)
+			select {
+			case ch <- v:
+			case <-time.After(time.Second):
+			}
+		}()
+		return ch
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		chans  func() []<-chan int
+		want   int
+		wantOK bool
+	}{
+		{"fastest wins", context.Background(), func() []<-chan int {
+			return []<-chan int{delayed(1, 200*time.Millisecond), delayed(2, time.Millisecond)}
+		}, 2, true},
+		{"all closed", context.Background(), func() []<-chan int {
+			return []<-chan int{sliceChan[int](), sliceChan[int]()}
+		}, 0, false},
+		{"cancelled", cancelled, func() []<-chan int {
+			return []<-chan int{make(chan int)}
+		}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := First(tt.ctx, tt.chans()...)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("First = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFanOutTry(t *testing.T) {
+	errRejected := errors.New("rejected")
+	outputs := FanOutTry(context.Background(), 2, sliceChan(1, 2, 3, 4, 5), func(n int) (int, error) {
+		if n%2 == 0 {
+			return 0, errRejected
+		}
+		return n * n, nil
+	})
+
+	var values []int
+	var failed int
+	for result := range FanInResults(outputs...) {
+		if result.Err != nil {
+			failed++
+			if !errors.Is(result.Err, errRejected) || !strings.Contains(result.Err.Error(), "worker ") {
+				t.Errorf("error %q does not wrap the cause with the worker and item", result.Err)
+			}
+			continue
+		}
+		values = append(values, result.Value)
+	}
+	slices.Sort(values)
+	if !slices.Equal(values, []int{1, 9, 25}) || failed != 2 {
+		t.Errorf("got values %v and %d failures, want [1 9 25] and 2", values, failed)
+	}
+}
+
+func TestFanOutErrorsDemo(t *testing.T) {
+	buf := captureOutput(t)
+	setLogLevel(t, LevelQuiet)
+	setPlainOutput(t, true)
+
+	runFanOutErrorsDemo(context.Background(), 4)
+	if !strings.Contains(buf.String(), "Results: 8 succeeded, 2 failed") {
+		t.Errorf("unexpected summary in %q", buf.String())
+	}
+}
+
+func TestFanOutFanInComparisonCancelled(t *testing.T) {
+	quietOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := FanOutFanInComparison(ctx)
+	if !summary.Cancelled || summary.Sequential != 0 {
+		t.Errorf("summary = %+v, want cancelled before the sequential run", summary)
+	}
+}
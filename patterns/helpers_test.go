@@ -0,0 +1,99 @@
+package patterns
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// expectPanic fails the test unless fn panics.
+func expectPanic(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", name)
+		}
+	}()
+	fn()
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// drain reads in until it is closed and returns everything it delivered.
+func drain[T any](in <-chan T) []T {
+	var all []T
+	for value := range in {
+		all = append(all, value)
+	}
+	return all
+}
+
+// sliceChan returns a closed channel holding values.
+func sliceChan[T any](values ...T) <-chan T {
+	ch := make(chan T, len(values))
+	for _, v := range values {
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
+
+// closesWithin fails the test unless ch is drained and closed within a second.
+func closesWithin[T any](t *testing.T, what string, ch <-chan T) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		drain(ch)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s was not closed", what)
+	}
+}
+
+// captureOutput sends the demos' output to a buffer for the rest of the test.
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := Output()
+	SetOutput(&buf)
+	t.Cleanup(func() { SetOutput(previous) })
+	return &buf
+}
+
+// quietOutput discards the demos' output for the rest of the test.
+func quietOutput(t *testing.T) {
+	t.Helper()
+	previous := Output()
+	SetOutput(io.Discard)
+	t.Cleanup(func() { SetOutput(previous) })
+}
+
+// setPlainOutput switches plain output on or off for the rest of the test.
+func setPlainOutput(t *testing.T, plain bool) {
+	t.Helper()
+	previous := PlainOutput()
+	SetPlainOutput(plain)
+	t.Cleanup(func() { SetPlainOutput(previous) })
+}
+
+// setLogLevel sets the log level for the rest of the test.
+func setLogLevel(t *testing.T, level LogLevel) {
+	t.Helper()
+	previous := CurrentLogLevel()
+	SetLogLevel(level)
+	t.Cleanup(func() { SetLogLevel(previous) })
+}
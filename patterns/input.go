@@ -0,0 +1,66 @@
+package patterns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promptInt asks for an integer of at least min, returning def when the user
+// just presses Enter. Invalid input is rejected and the question asked again.
+func promptInt(label string, def, min int) int {
+	for {
+		console.Printf("%s [%d]: ", label, def)
+		input := readLine()
+		if input == "" {
+			return def
+		}
+
+		value, err := strconv.Atoi(input)
+		if err == nil && value >= min {
+			return value
+		}
+		console.Printf("Invalid value %q: enter a whole number of at least %d\n", input, min)
+	}
+}
+
+// promptDuration asks for a non-negative duration such as "2s" or "500ms",
+// returning def when the user just presses Enter or types something invalid.
+func promptDuration(label string, def time.Duration) time.Duration {
+	console.Printf("%s [%v]: ", label, def)
+	input := readLine()
+	if input == "" {
+		return def
+	}
+
+	value, err := time.ParseDuration(input)
+	if err != nil || value < 0 {
+		console.Printf("Invalid duration %q, using default %v\n", input, def)
+		return def
+	}
+	return value
+}
+
+// promptFloat asks for a positive number, returning def when the user just
+// presses Enter or types something invalid.
+func promptFloat(label string, def float64) float64 {
+	console.Printf("%s [%g]: ", label, def)
+	input := readLine()
+	if input == "" {
+		return def
+	}
+
+	value, err := strconv.ParseFloat(input, 64)
+	if err != nil || value <= 0 {
+		console.Printf("Invalid value %q, using default %g\n", input, def)
+		return def
+	}
+	return value
+}
+
+func readLine() string {
+	var input string
+	fmt.Scanln(&input)
+	return strings.TrimSpace(input)
+}
@@ -0,0 +1,103 @@
+package patterns
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyedRateLimiter keeps an independent token bucket per key (e.g. per API
+// key), creating buckets on first use. Buckets are refilled lazily, from the
+// time that has passed since the key was last seen, so a limiter with many
+// tenants runs no goroutines at all.
+type KeyedRateLimiter struct {
+	rate    float64
+	burst   int
+	idleTTL time.Duration
+	buckets map[string]*keyedBucket
+	stopped bool
+	mutex   sync.Mutex
+}
+
+type keyedBucket struct {
+	tokens   float64
+	lastUsed time.Time
+}
+
+// NewKeyedRateLimiter creates a limiter giving each key rate requests per
+// second with bursts of burst. Keys unused for idleTTL are dropped by
+// Cleanup. Like NewRateLimiter it panics if rate is not positive or burst is
+// less than one.
+func NewKeyedRateLimiter(rate float64, burst int, idleTTL time.Duration) *KeyedRateLimiter {
+	checkRate(rate)
+	checkBurst(burst)
+
+	return &KeyedRateLimiter{
+		rate:    rate,
+		burst:   burst,
+		idleTTL: idleTTL,
+		buckets: make(map[string]*keyedBucket),
+	}
+}
+
+// Allow spends a token from key's bucket if one is available. After Stop it
+// always returns false.
+func (kl *KeyedRateLimiter) Allow(key string) bool {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+
+	if kl.stopped {
+		return false
+	}
+
+	now := time.Now()
+	bucket, ok := kl.buckets[key]
+	if !ok {
+		bucket = &keyedBucket{tokens: float64(kl.burst)}
+		kl.buckets[key] = bucket
+	} else {
+		// Add the tokens earned since the key was last seen
+		earned := now.Sub(bucket.lastUsed).Seconds() * kl.rate
+		bucket.tokens = min(bucket.tokens+earned, float64(kl.burst))
+	}
+	bucket.lastUsed = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Cleanup drops the buckets of keys that have been idle for longer than the
+// idle TTL and returns how many were removed. A dropped key starts again
+// with a full bucket.
+func (kl *KeyedRateLimiter) Cleanup() int {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+
+	var removed int
+	for key, bucket := range kl.buckets {
+		if time.Since(bucket.lastUsed) > kl.idleTTL {
+			delete(kl.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Stop drops every key and refuses all requests from then on. Stop is safe
+// to call more than once.
+func (kl *KeyedRateLimiter) Stop() {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+
+	kl.stopped = true
+	clear(kl.buckets)
+}
+
+// Len returns the number of keys currently tracked.
+func (kl *KeyedRateLimiter) Len() int {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+	return len(kl.buckets)
+}
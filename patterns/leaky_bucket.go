@@ -0,0 +1,80 @@
+package patterns
+
+import (
+	"sync"
+	"time"
+)
+
+// LeakyBucket queues up to capacity requests and releases them at a fixed
+// rate. Unlike the token bucket it never lets a burst through: admitted
+// requests leave the bucket evenly spaced no matter how fast they arrived.
+type LeakyBucket struct {
+	queue    chan struct{}
+	drained  chan time.Time
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLeakyBucket creates a bucket that holds up to capacity pending requests
+// and releases rate requests per second. It panics if rate is not positive.
+func NewLeakyBucket(rate float64, capacity int) *LeakyBucket {
+	checkRate(rate)
+
+	lb := &LeakyBucket{
+		queue:   make(chan struct{}, capacity),
+		drained: make(chan time.Time),
+		done:    make(chan struct{}),
+	}
+	go lb.drain(time.Duration(float64(time.Second) / rate))
+	return lb
+}
+
+// Submit queues a request and reports whether it was accepted. It returns
+// false immediately when the bucket is full.
+func (lb *LeakyBucket) Submit() bool {
+	select {
+	case lb.queue <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drained delivers the release time of each accepted request, in order. If
+// nobody reads it the bucket stops draining and Submit starts rejecting.
+func (lb *LeakyBucket) Drained() <-chan time.Time {
+	return lb.drained
+}
+
+func (lb *LeakyBucket) drain(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lb.done:
+			return
+		case <-ticker.C:
+		}
+
+		select {
+		case <-lb.queue:
+		default:
+			continue
+		}
+
+		select {
+		case lb.drained <- time.Now():
+		case <-lb.done:
+			return
+		}
+	}
+}
+
+// Stop ends the drain goroutine. Requests still queued are never released.
+// Stop is safe to call more than once.
+func (lb *LeakyBucket) Stop() {
+	lb.stopOnce.Do(func() {
+		close(lb.done)
+	})
+}
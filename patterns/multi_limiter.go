@@ -0,0 +1,119 @@
+package patterns
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MultiLimiter enforces several limits at once (e.g. 10/sec and 1000/hour):
+// a request goes through only when every underlying limiter allows it.
+//
+// Tokens are reserved from every member before any is kept, and handed back
+// if one of them refuses, so a request never holds a token from one limiter
+// while blocking on another.
+type MultiLimiter struct {
+	limiters []reserver
+}
+
+// reserver is a Limiter whose tokens can be reserved and handed back, which
+// MultiLimiter needs to take from several limiters at once.
+type reserver interface {
+	Limiter
+	Reserve() *Reservation
+}
+
+var (
+	_ reserver = (*RateLimiter)(nil)
+	_ Limiter  = (*MultiLimiter)(nil)
+)
+
+// multiLimiterPoll is how long Wait pauses before retrying when a refusing
+// limiter's next token is already due, giving its refill a chance to land.
+const multiLimiterPoll = 10 * time.Millisecond
+
+// NewMultiLimiter combines limiters into one that grants a token only when
+// all of them do. Every member must support Reserve, as *RateLimiter does;
+// it panics on one that doesn't, since a token taken from it could not be
+// handed back.
+func NewMultiLimiter(limiters ...Limiter) *MultiLimiter {
+	ml := &MultiLimiter{}
+	for _, limiter := range limiters {
+		r, ok := limiter.(reserver)
+		if !ok {
+			panic(fmt.Sprintf("patterns: MultiLimiter members must support Reserve, got %T", limiter))
+		}
+		ml.limiters = append(ml.limiters, r)
+	}
+	return ml
+}
+
+// Allow takes a token from every limiter if all of them have one available,
+// and takes nothing otherwise.
+func (ml *MultiLimiter) Allow() bool {
+	ok, _, _ := ml.tryAcquire()
+	return ok
+}
+
+// Wait blocks until every limiter grants a token or ctx is done. If a member
+// has been stopped it returns ErrLimiterStopped straight away, since that
+// limiter will never grant a token again.
+func (ml *MultiLimiter) Wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ok, delay, err := ml.tryAcquire()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// tryAcquire attempts to take a token from every limiter. When it fails it
+// returns how long to wait before trying again, or ErrLimiterStopped if a
+// member has been stopped and waiting is pointless.
+func (ml *MultiLimiter) tryAcquire() (bool, time.Duration, error) {
+	held := make([]*Reservation, 0, len(ml.limiters))
+	release := func() {
+		for _, r := range held {
+			r.Cancel()
+		}
+	}
+
+	var delay time.Duration
+	for _, limiter := range ml.limiters {
+		r := limiter.Reserve()
+		if r.stopped {
+			release()
+			return false, 0, ErrLimiterStopped
+		}
+		if !r.OK() {
+			if r.Delay() > delay {
+				delay = r.Delay()
+			}
+			continue
+		}
+		held = append(held, r)
+	}
+	if len(held) < len(ml.limiters) {
+		release()
+		if delay <= 0 {
+			delay = multiLimiterPoll
+		}
+		return false, delay, nil
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,165 @@
+package patterns
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// plainOutput makes the demos print ASCII instead of emoji, for logs and
+// terminals that cannot show them. It follows the NO_COLOR convention: any
+// non-empty value turns it on.
+var plainOutput atomic.Bool
+
+func init() {
+	plainOutput.Store(os.Getenv("NO_COLOR") != "")
+}
+
+// SetPlainOutput switches plain output on or off. In plain mode status labels
+// such as CircuitState use bracketed ASCII like [OPEN], the success, failure
+// and warning markers become [OK], [FAIL] and [WARN], and other emoji, which
+// are only decoration, are dropped.
+func SetPlainOutput(plain bool) {
+	plainOutput.Store(plain)
+}
+
+// PlainOutput reports whether plain output is on.
+func PlainOutput() bool {
+	return plainOutput.Load()
+}
+
+// LogLevel controls how much the demos print.
+type LogLevel int32
+
+const (
+	// LevelQuiet prints only headings, results and summaries, leaving out
+	// the line-per-request detail of each run.
+	LevelQuiet LogLevel = iota
+	// LevelVerbose prints everything. It is the default.
+	LevelVerbose
+)
+
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(int32(LevelVerbose))
+}
+
+// SetLogLevel sets how much the demos print from now on.
+func SetLogLevel(level LogLevel) {
+	logLevel.Store(int32(level))
+}
+
+// CurrentLogLevel reports the level set by SetLogLevel.
+func CurrentLogLevel() LogLevel {
+	return LogLevel(logLevel.Load())
+}
+
+// label picks the decorated or the plain form of a label for the current
+// output mode.
+func label(fancy, plain string) string {
+	if PlainOutput() {
+		return plain
+	}
+	return fancy
+}
+
+// plainLabels maps the emoji that carry meaning to ASCII labels; emoji not
+// listed here are only decoration and are dropped in plain mode.
+var plainLabels = map[string]string{
+	"✅": "[OK]",
+	"❌": "[FAIL]",
+	"⚠": "[WARN]",
+	"→": "->",
+}
+
+// emojiPattern matches one emoji (or arrow), its optional variation
+// selector and the spaces after it.
+var emojiPattern = regexp.MustCompile(`[\x{2190}-\x{21FF}\x{2300}-\x{23FF}\x{2600}-\x{27BF}\x{2B00}-\x{2BFF}\x{1F000}-\x{1FAFF}]\x{FE0F}? *`)
+
+// plainText rewrites s for plain mode.
+func plainText(s string) string {
+	return emojiPattern.ReplaceAllStringFunc(s, func(match string) string {
+		symbol := strings.TrimSuffix(strings.TrimRight(match, " "), "\uFE0F")
+		replacement := plainLabels[symbol]
+		if replacement != "" && strings.HasSuffix(match, " ") {
+			replacement += " "
+		}
+		return replacement
+	})
+}
+
+// console is where the demos print. It behaves like the fmt print functions
+// but applies plain mode, and serializes writes so lines printed from
+// different goroutines do not interleave mid-line.
+var console = &consoleWriter{w: os.Stdout}
+
+type consoleWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// SetOutput sends everything the demos print to w instead of standard
+// output, for capturing it in tests or embedding the demos in another
+// program. Prompts are written there too; input is still read from standard
+// input.
+func SetOutput(w io.Writer) {
+	console.mutex.Lock()
+	defer console.mutex.Unlock()
+	console.w = w
+}
+
+// Output returns the writer set by SetOutput, so that callers can put it
+// back after redirecting the demos for a while.
+func Output() io.Writer {
+	console.mutex.Lock()
+	defer console.mutex.Unlock()
+	return console.w
+}
+
+func (c *consoleWriter) Print(args ...any) {
+	c.write(fmt.Sprint(args...))
+}
+
+func (c *consoleWriter) Printf(format string, args ...any) {
+	c.write(fmt.Sprintf(format, args...))
+}
+
+func (c *consoleWriter) Println(args ...any) {
+	c.write(fmt.Sprintln(args...))
+}
+
+// Detail, Detailf and Detailln print like Print, Printf and Println, but
+// only at LevelVerbose. They are for the progress of a run (each request,
+// job or intermediate step) as opposed to its outcome.
+func (c *consoleWriter) Detail(args ...any) {
+	if CurrentLogLevel() >= LevelVerbose {
+		c.Print(args...)
+	}
+}
+
+func (c *consoleWriter) Detailf(format string, args ...any) {
+	if CurrentLogLevel() >= LevelVerbose {
+		c.Printf(format, args...)
+	}
+}
+
+func (c *consoleWriter) Detailln(args ...any) {
+	if CurrentLogLevel() >= LevelVerbose {
+		c.Println(args...)
+	}
+}
+
+func (c *consoleWriter) write(s string) {
+	if PlainOutput() {
+		s = plainText(s)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	io.WriteString(c.w, s)
+}
@@ -0,0 +1,69 @@
+package patterns
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"✅ Job 1 done", "[OK] Job 1 done"},
+		{"❌ Failed", "[FAIL] Failed"},
+		{"⚠️  Warning: slow", "[WARN] Warning: slow"},
+		{"CLOSED → OPEN", "CLOSED -> OPEN"},
+		{"🚀 Starting workers", "Starting workers"},
+		{"📊 Results: 3/3", "Results: 3/3"},
+		{"no emoji here", "no emoji here"},
+	}
+	for _, tt := range tests {
+		if got := plainText(tt.in); got != tt.want {
+			t.Errorf("plainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConsolePlainMode(t *testing.T) {
+	tests := []struct {
+		plain bool
+		want  string
+	}{
+		{false, "✅ ok 🟢 CLOSED\n"},
+		{true, "[OK] ok [CLOSED]\n"},
+	}
+	for _, tt := range tests {
+		buf := captureOutput(t)
+		setPlainOutput(t, tt.plain)
+
+		console.Printf("✅ ok %s\n", CLOSED)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("plain=%v: printed %q, want %q", tt.plain, got, tt.want)
+		}
+	}
+}
+
+func TestConsoleLogLevel(t *testing.T) {
+	tests := []struct {
+		level LogLevel
+		want  string
+	}{
+		{LevelVerbose, "heading\ndetail 1\nresult\n"},
+		{LevelQuiet, "heading\nresult\n"},
+	}
+	for _, tt := range tests {
+		buf := captureOutput(t)
+		setLogLevel(t, tt.level)
+
+		console.Println("heading")
+		console.Detailf("detail %d\n", 1)
+		console.Println("result")
+		if got := buf.String(); got != tt.want {
+			t.Errorf("level %d: printed %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestOutputReturnsCurrentWriter(t *testing.T) {
+	buf := captureOutput(t)
+	if Output() != buf {
+		t.Fatalf("Output() did not return the writer passed to SetOutput")
+	}
+}
@@ -1,152 +1,845 @@
 package patterns
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
-func Pipeline() {
-	fmt.Println("=== Pipeline Pattern ===")
-	fmt.Println("Processing data through multiple concurrent stages")
-	fmt.Println("Use case: Text processing pipeline (clean -> transform -> analyze)")
-	fmt.Println()
+// Stage is one step of a pipeline: it consumes values from in and returns a
+// channel of results, which it closes once in is exhausted or ctx is done.
+type Stage[I, O any] func(ctx context.Context, in <-chan I) <-chan O
 
+// Chain connects two stages with compatible types into a single stage.
+// Chains can be nested to build pipelines of any length.
+func Chain[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return func(ctx context.Context, in <-chan A) <-chan C {
+		return second(ctx, first(ctx, in))
+	}
+}
+
+// MapStage builds a Stage that applies fn to each value in its own goroutine,
+// taking care of the channel plumbing. Every send also watches ctx, so a
+// cancelled pipeline shuts down even if nobody reads its output any more.
+func MapStage[I, O any](fn func(I) O) Stage[I, O] {
+	return BufferedMapStage(0, fn)
+}
+
+// BufferedMapStage is MapStage with an output channel of the given buffer
+// size, letting the stage run up to buffer items ahead of the next one
+// instead of handing off in lock-step.
+func BufferedMapStage[I, O any](buffer int, fn func(I) O) Stage[I, O] {
+	return func(ctx context.Context, in <-chan I) <-chan O {
+		out := make(chan O, buffer)
+		go func() {
+			defer close(out)
+			for value := range in {
+				select {
+				case out <- fn(value):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// BuildPipeline wires any number of string transforms together, each running
+// in its own goroutine, and applies them to src in the order given. With no
+// stages it returns src unchanged.
+func BuildPipeline(ctx context.Context, src <-chan string, stages ...func(string) string) <-chan string {
+	out := src
+	for _, fn := range stages {
+		out = MapStage(fn)(ctx, out)
+	}
+	return out
+}
+
+// ParallelStage builds a Stage that runs n workers over the same input
+// channel and merges their results into one output. Use it to widen a slow
+// stage. Results come out in completion order, not input order. It panics
+// if n is less than one.
+func ParallelStage[I, O any](n int, fn func(I) O) Stage[I, O] {
+	if n < 1 {
+		panic(fmt.Sprintf("patterns: ParallelStage needs at least 1 worker, got %d", n))
+	}
+
+	return func(ctx context.Context, in <-chan I) <-chan O {
+		out := make(chan O)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for value := range in {
+					select {
+					case out <- fn(value):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+		return out
+	}
+}
+
+// StageMetrics describes the work done by an instrumented stage. Busy is the
+// time spent inside the stage function; Blocked is the time spent waiting to
+// receive input or to hand results downstream.
+type StageMetrics struct {
+	Name    string
+	Items   int
+	Busy    time.Duration
+	Blocked time.Duration
+}
+
+// InstrumentedStage is MapStage with timing. The returned metrics are updated
+// by the stage's goroutine and are safe to read once its output channel has
+// been closed.
+func InstrumentedStage[I, O any](name string, fn func(I) O) (Stage[I, O], *StageMetrics) {
+	metrics := &StageMetrics{Name: name}
+	stage := func(ctx context.Context, in <-chan I) <-chan O {
+		out := make(chan O)
+		go func() {
+			defer close(out)
+			for {
+				waitStart := time.Now()
+				var value I
+				var ok bool
+				select {
+				case value, ok = <-in:
+				case <-ctx.Done():
+					return
+				}
+				metrics.Blocked += time.Since(waitStart)
+				if !ok {
+					return
+				}
+
+				busyStart := time.Now()
+				result := fn(value)
+				metrics.Busy += time.Since(busyStart)
+				metrics.Items++
+
+				sendStart := time.Now()
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+				metrics.Blocked += time.Since(sendStart)
+			}
+		}()
+		return out
+	}
+	return stage, metrics
+}
+
+// Merge interleaves values from all of ins onto a single channel. The output
+// is closed only after every input has been closed, or as soon as ctx is done.
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	return FanInContext(ctx, ins...)
+}
+
+// Tee duplicates every value from in onto n output channels, closing them all
+// once in is exhausted or ctx is done. Each value is offered to all outputs at
+// once, so consumers may read in any order, but the next value is not taken
+// from in until every consumer has received the current one: the slowest
+// consumer sets the pace.
+func Tee[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	outs := make([]chan T, n)
+	result := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		result[i] = outs[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for value := range in {
+			var wg sync.WaitGroup
+			for _, out := range outs {
+				wg.Add(1)
+				go func(ch chan<- T) {
+					defer wg.Done()
+					select {
+					case ch <- value:
+					case <-ctx.Done():
+					}
+				}(out)
+			}
+			wg.Wait()
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return result
+}
+
+// Filter forwards only the values from in for which keep returns true,
+// closing its output when in is exhausted or ctx is done.
+func Filter[T any](ctx context.Context, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for value := range in {
+			if !keep(value) {
+				continue
+			}
+			select {
+			case out <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Take forwards at most n values from in and then calls cancel so the stages
+// upstream stop producing. ctx should be the context cancel belongs to: a
+// consumer that stops reading before n values calls cancel, and Take gives up
+// on the value it was sending instead of blocking forever. Anything still in
+// flight on in is drained, so a sender that ignores the context cannot block
+// forever either.
+func Take[T any](ctx context.Context, in <-chan T, n int, cancel context.CancelFunc) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer func() {
+			cancel()
+			go func() {
+				for range in {
+				}
+			}()
+		}()
+		for i := 0; i < n; i++ {
+			value, ok := <-in
+			if !ok {
+				return
+			}
+			select {
+			case out <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Batch groups values from in into slices of up to size. A partial batch is
+// flushed early once maxWait has passed since its first value arrived, and
+// whatever is left is flushed when in closes.
+func Batch[T any](ctx context.Context, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+
+		var batch []T
+		var timer *time.Timer
+		var timeout <-chan time.Time
+
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+			select {
+			case out <- batch:
+				batch = nil
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case value, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, value)
+				if len(batch) == 1 {
+					timer = time.NewTimer(maxWait)
+					timeout = timer.C
+				}
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-timeout:
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Item carries a value through a pipeline together with any error raised
+// while producing it.
+type Item[T any] struct {
+	Value T
+	Err   error
+}
+
+// TryStage builds a Stage over Items from a function that can fail. Items
+// that already carry an error are forwarded untouched without calling fn, so
+// a failure skips the remaining stages for that item while the others keep
+// flowing.
+func TryStage[I, O any](fn func(I) (O, error)) Stage[Item[I], Item[O]] {
+	return MapStage(func(item Item[I]) Item[O] {
+		if item.Err != nil {
+			return Item[O]{Err: item.Err}
+		}
+		value, err := fn(item.Value)
+		return Item[O]{Value: value, Err: err}
+	})
+}
+
+// MapItems is TryStage for a function that cannot fail.
+func MapItems[I, O any](fn func(I) O) Stage[Item[I], Item[O]] {
+	return TryStage(func(value I) (O, error) {
+		return fn(value), nil
+	})
+}
+
+// Pipeline runs the interactive pipeline menu until the user goes back or
+// ctx is cancelled, which also cuts the comparison short.
+func Pipeline(ctx context.Context) {
+	pipelineHeader()
+	for {
+		console.Println("Pipeline Demo Options:")
+		console.Println("1. ⚡ Concurrent vs sequential (comparison)")
+		console.Println("2. ⚠️  Error propagation")
+		console.Println("3. 🚀 Parallel stages")
+		console.Println("4. 📦 Buffered stages")
+		console.Println("5. 🔀 Fan-out and merge")
+		console.Println("6. 📈 Stage metrics")
+		console.Println("7. 🔱 Tee to multiple consumers")
+		console.Println("8. 🧺 Batching results")
+		console.Println("9. 👀 Preview the first results")
+		console.Println("0. Back to main menu")
+		console.Print("Select demo (0-9): ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+		console.Println()
+
+		switch choice {
+		case 1:
+			runPipelineComparison(ctx)
+		case 2:
+			runPipelineErrorDemo()
+		case 3:
+			runParallelStageDemo(promptInt("Workers per stage", 4, 1))
+		case 4:
+			runBufferedStageDemo(promptInt("Buffer size per stage", 8, 0))
+		case 5:
+			runMergeStageDemo(promptInt("Transform workers", 3, 1))
+		case 6:
+			runStageMetricsDemo()
+		case 7:
+			runTeeDemo()
+		case 8:
+			runBatchDemo(promptInt("Batch size", 3, 1))
+		case 9:
+			runTakeDemo(promptInt("Results to preview", 2, 1))
+		case 0:
+			return
+		default:
+			console.Println("Invalid choice. Please try again.")
+			console.Println()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		console.Println("\nPress Enter to continue...")
+		fmt.Scanf("\n")
+		console.Println()
+	}
+}
+
+// PipelineComparison runs the pipeline comparison without asking for input.
+// Cancelling ctx cuts it short.
+func PipelineComparison(ctx context.Context) RunSummary {
+	pipelineHeader()
+	return runPipelineComparison(ctx)
+}
+
+func pipelineHeader() {
+	console.Println("=== Pipeline Pattern ===")
+	console.Println("Processing data through multiple concurrent stages")
+	console.Println("Use case: Text processing pipeline (clean -> transform -> analyze)")
+	console.Println()
+}
+
+func runPipelineComparison(ctx context.Context) RunSummary {
 	// Run concurrent version
-	fmt.Println("Running CONCURRENT version...")
+	console.Detailln("Running CONCURRENT version...")
 	concurrentStart := time.Now()
-	runPipelineConcurrent()
+	results := runPipelineConcurrent(ctx)
 	concurrentDuration := time.Since(concurrentStart)
 
-	fmt.Printf("\nCONCURRENT version took: %v\n\n", concurrentDuration)
+	summary := RunSummary{
+		Pattern:    "pipeline",
+		Completed:  len(results),
+		Concurrent: concurrentDuration,
+	}
+	if cancelled(ctx, &summary) {
+		return summary
+	}
+
+	console.Printf("Processed %d items through 3-stage pipeline\n", len(results))
+	stats := SummarizeResults(results)
+	console.Printf("📊 %d words total, average length %.1f, longest: %q\n",
+		stats.TotalWords, stats.AverageLength, stats.Longest)
+	summary.Details = map[string]any{
+		"totalWords":    stats.TotalWords,
+		"averageLength": stats.AverageLength,
+		"longest":       stats.Longest,
+	}
+
+	console.Printf("\nCONCURRENT version took: %v\n\n", concurrentDuration)
 
 	// Run sequential version for comparison
-	fmt.Println("Running SEQUENTIAL version for comparison...")
+	console.Detailln("Running SEQUENTIAL version for comparison...")
 	sequentialStart := time.Now()
-	runPipelineSequential()
-	sequentialDuration := time.Since(sequentialStart)
+	runPipelineSequential(ctx)
+	summary.Sequential = time.Since(sequentialStart)
+	if cancelled(ctx, &summary) {
+		return summary
+	}
+
+	console.Printf("\nSEQUENTIAL version took: %v\n", summary.Sequential)
+	console.Printf("Speedup: %.2fx faster with concurrency!\n\n", summary.Speedup())
+	return summary
+}
 
-	fmt.Printf("\nSEQUENTIAL version took: %v\n", sequentialDuration)
-	fmt.Printf("Speedup: %.2fx faster with concurrency!\n\n", float64(sequentialDuration)/float64(concurrentDuration))
+func runPipelineConcurrent(ctx context.Context) []Analysis {
+	return ProcessTexts(ctx, pipelineInput)
 }
 
-func runPipelineConcurrent() {
-	
-	// Sample data to process
+func runPipelineErrorDemo() {
+	console.Println("⚠️  === Pipeline Error Propagation ===")
+	console.Println("A validation stage rejects malformed input; the error skips the")
+	console.Println("remaining stages for that item while the others keep flowing")
+	console.Println()
+
 	rawData := []string{
 		"  Hello World!!!  ",
+		"   ",
 		"  Go is AWESOME  ",
-		"  Concurrency ROCKS!!!  ",
-		"  Programming is FUN  ",
-		"  Pipelines are COOL  ",
+		"  12345!!!  ",
 		"  Channels are GREAT  ",
-		"  Goroutines RULE  ",
-		"  Synchronization MATTERS  ",
 	}
 
-	// Stage 1: Clean data (trim whitespace, remove extra punctuation)
-	cleaned := cleanStage(generator(rawData))
-	
-	// Stage 2: Transform data (convert to lowercase, add prefix)
-	transformed := transformStage(cleaned)
-	
-	// Stage 3: Analyze data (count words, measure length)
-	analyzed := analyzeStage(transformed)
+	items := make([]Item[string], len(rawData))
+	for i, data := range rawData {
+		items[i] = Item[string]{Value: data}
+	}
 
-	// Count results
-	var processed int
-	for range analyzed {
+	pipeline := Chain(Chain(Chain(
+		MapItems(demoTextStages.clean),
+		TryStage(validateText)),
+		MapItems(demoTextStages.transform)),
+		MapItems(demoTextStages.analyze))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var processed, failed int
+	for item := range pipeline(ctx, generator(ctx, items)) {
+		if item.Err != nil {
+			failed++
+			console.Detailf("❌ %v\n", item.Err)
+			continue
+		}
 		processed++
+		console.Detailf("✅ %s\n", item.Value)
 	}
-	
-	fmt.Printf("Processed %d items through 3-stage pipeline\n", processed)
+
+	console.Printf("\n📊 Results: %d processed, %d rejected\n", processed, failed)
 }
 
-func runPipelineSequential() {
-	rawData := []string{
-		"  Hello World!!!  ",
-		"  Go is AWESOME  ",
-		"  Concurrency ROCKS!!!  ",
-		"  Programming is FUN  ",
-		"  Pipelines are COOL  ",
-		"  Channels are GREAT  ",
-		"  Goroutines RULE  ",
-		"  Synchronization MATTERS  ",
+func runParallelStageDemo(workers int) {
+	console.Println("🚀 === Parallel Pipeline Stages ===")
+	console.Printf("Each stage runs %d workers; output order is no longer guaranteed\n", workers)
+	console.Println()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	singleStart := time.Now()
+	for range parallelTextPipeline(1, demoTextStages)(ctx, generator(ctx, pipelineInput)) {
 	}
+	singleDuration := time.Since(singleStart)
+	console.Printf("1 worker per stage took: %v\n", singleDuration)
 
-	for _, data := range rawData {
-		// Stage 1: Clean
-		time.Sleep(50 * time.Millisecond) // Simulate cleaning work
-		cleaned := strings.TrimSpace(data)
-		cleaned = strings.ReplaceAll(cleaned, "!!!", "!")
+	parallelStart := time.Now()
+	for result := range parallelTextPipeline(workers, demoTextStages)(ctx, generator(ctx, pipelineInput)) {
+		console.Detailf("  %s\n", result)
+	}
+	parallelDuration := time.Since(parallelStart)
+	console.Printf("%d workers per stage took: %v\n", workers, parallelDuration)
+
+	console.Printf("\nSpeedup: %.2fx with %d workers per stage\n", float64(singleDuration)/float64(parallelDuration), workers)
+	console.Println("💡 The 50ms clean stage bottlenecks a single-worker pipeline")
+}
 
-		// Stage 2: Transform  
-		time.Sleep(30 * time.Millisecond) // Simulate transform work
-		transformed := "processed: " + strings.ToLower(cleaned)
+func runBufferedStageDemo(buffer int) {
+	console.Println("📦 === Buffered Pipeline Stages ===")
+	console.Println("Buffers let a fast stage run ahead instead of waiting on the next one")
+	console.Println()
 
-		// Stage 3: Analyze
-		time.Sleep(40 * time.Millisecond) // Simulate analysis work
-		wordCount := len(strings.Fields(transformed))
-		_ = fmt.Sprintf("%s (words: %d, length: %d)", transformed, wordCount, len(transformed))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	unbufferedStart := time.Now()
+	for range textPipeline(0, demoTextStages)(ctx, generator(ctx, pipelineInput)) {
+	}
+	unbufferedDuration := time.Since(unbufferedStart)
+
+	bufferedStart := time.Now()
+	for range textPipeline(buffer, demoTextStages)(ctx, generator(ctx, pipelineInput)) {
+	}
+	bufferedDuration := time.Since(bufferedStart)
+
+	console.Printf("Buffer 0 (lock-step hand-off) took: %v\n", unbufferedDuration)
+	console.Printf("Buffer %d took: %v\n", buffer, bufferedDuration)
+	console.Println("\n💡 Buffering smooths uneven stage timings; the slowest stage still")
+	console.Println("   sets the overall throughput")
+}
+
+func runMergeStageDemo(workers int) {
+	console.Println("🔀 === Fan-out and Merge ===")
+	console.Printf("Cleaned items fan out to %d transform workers, then merge back for analysis\n", workers)
+	console.Println()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	cleaned := cleanStage(0, demoTextStages.clean)(ctx, generator(ctx, pipelineInput))
+
+	// Fan-out: every transform worker reads from the same cleaned channel
+	transformed := make([]<-chan string, workers)
+	for i := range transformed {
+		transformed[i] = transformStage(0, demoTextStages.transform)(ctx, cleaned)
+	}
+
+	// Fan-in: merge the workers back into a single stream for analysis
+	var processed int
+	for result := range analyzeStage(0, demoTextStages.analyze)(ctx, Merge(ctx, transformed...)) {
+		processed++
+		console.Detailf("  %s\n", result)
 	}
 
-	fmt.Printf("Processed %d items sequentially through all stages\n", len(rawData))
+	console.Printf("\n📊 Processed %d items in %v\n", processed, time.Since(start))
 }
 
-func generator(data []string) <-chan string {
-	out := make(chan string)
+func runStageMetricsDemo() {
+	console.Println("📈 === Pipeline Stage Metrics ===")
+	console.Println("Each stage records how long it was busy versus blocked on its neighbours")
+	console.Println()
+
+	clean, cleanMetrics := InstrumentedStage("clean", demoTextStages.clean)
+	transform, transformMetrics := InstrumentedStage("transform", demoTextStages.transform)
+	analyze, analyzeMetrics := InstrumentedStage("analyze", demoTextStages.analyze)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	for range Chain(Chain(clean, transform), analyze)(ctx, generator(ctx, pipelineInput)) {
+	}
+	console.Printf("Pipeline finished in %v\n\n", time.Since(start))
+
+	console.Printf("%-10s %6s %12s %12s\n", "Stage", "Items", "Busy", "Blocked")
+	for _, m := range []*StageMetrics{cleanMetrics, transformMetrics, analyzeMetrics} {
+		console.Printf("%-10s %6d %12v %12v\n", m.Name, m.Items,
+			m.Busy.Round(time.Millisecond), m.Blocked.Round(time.Millisecond))
+	}
+	console.Println("\n💡 The busiest stage is the bottleneck; the others spend the difference blocked")
+}
+
+func runTeeDemo() {
+	console.Println("🔱 === Tee to Multiple Consumers ===")
+	console.Println("Cleaned items go both to a logger and on through the rest of the pipeline")
+	console.Println()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cleaned := cleanStage(0, demoTextStages.clean)(ctx, generator(ctx, pipelineInput[:4]))
+	branches := Tee(ctx, cleaned, 2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		defer close(out)
-		for _, item := range data {
-			out <- item
+		defer wg.Done()
+		for value := range branches[0] {
+			console.Detailf("📝 log: cleaned %q\n", value)
 		}
 	}()
-	return out
+
+	analyzed := Chain(
+		transformStage(0, demoTextStages.transform),
+		analyzeStage(0, demoTextStages.analyze))(ctx, branches[1])
+	for result := range analyzed {
+		console.Detailf("✅ %s\n", result)
+	}
+	wg.Wait()
 }
 
-func cleanStage(input <-chan string) <-chan string {
-	out := make(chan string)
-	go func() {
-		defer close(out)
-		for data := range input {
-			// Simulate cleaning work
-			time.Sleep(50 * time.Millisecond)
-			
-			cleaned := strings.TrimSpace(data)
-			cleaned = strings.ReplaceAll(cleaned, "!!!", "!")
-			out <- cleaned
+func runBatchDemo(size int) {
+	console.Println("🧺 === Batching Pipeline Results ===")
+	console.Printf("Analyzed items are grouped into batches of up to %d, flushed after 150ms\n", size)
+	console.Println()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	results := textPipeline(0, demoTextStages)(ctx, generator(ctx, pipelineInput))
+	for batch := range Batch(ctx, results, size, 150*time.Millisecond) {
+		console.Detailf("📦 [%6v] batch of %d\n", time.Since(start).Round(time.Millisecond), len(batch))
+		for _, result := range batch {
+			console.Detailf("     %s\n", result)
 		}
-	}()
-	return out
+	}
 }
 
-func transformStage(input <-chan string) <-chan string {
-	out := make(chan string)
-	go func() {
-		defer close(out)
-		for data := range input {
-			// Simulate transformation work
-			time.Sleep(30 * time.Millisecond)
-			
-			transformed := "processed: " + strings.ToLower(data)
-			out <- transformed
+func runTakeDemo(n int) {
+	console.Println("👀 === Previewing Pipeline Results ===")
+	console.Printf("Only the first %d results are read; the rest of the pipeline is cancelled\n", n)
+	console.Println()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	for result := range Take(ctx, RunPipeline(ctx, pipelineInput), n, cancel) {
+		console.Detailf("  %s\n", result)
+	}
+	console.Printf("\n⏱️  Preview of %d/%d items took %v\n", min(n, len(pipelineInput)), len(pipelineInput), time.Since(start))
+}
+
+// runPipelineSequential runs every item through the same stage functions as
+// the concurrent pipeline, one item and one stage at a time, so the two
+// timings compare the same work.
+func runPipelineSequential(ctx context.Context) {
+	processed := 0
+	for _, data := range pipelineInput {
+		if ctx.Err() != nil {
+			break
 		}
-	}()
-	return out
+
+		cleaned := demoTextStages.clean(data)
+		if !hasText(cleaned) {
+			continue
+		}
+		demoTextStages.analyze(demoTextStages.transform(cleaned))
+		processed++
+	}
+
+	console.Printf("Processed %d items sequentially through all stages\n", processed)
+}
+
+// RunPipeline pushes data through the clean -> transform -> analyze text
+// pipeline. Cancelling ctx stops every stage, so the caller may stop reading
+// part-way through without leaking goroutines.
+func RunPipeline(ctx context.Context, data []string) <-chan Analysis {
+	cleaned := BuildPipeline(ctx, generator(ctx, data), demoTextStages.clean)
+	transformed := BuildPipeline(ctx, Filter(ctx, cleaned, hasText), demoTextStages.transform)
+	return MapStage(demoTextStages.analyze)(ctx, transformed)
 }
 
-func analyzeStage(input <-chan string) <-chan string {
-	out := make(chan string)
+// ProcessTexts runs data through the text pipeline and returns the analyzed
+// results in input order. If ctx is cancelled it returns what was finished.
+func ProcessTexts(ctx context.Context, data []string) []Analysis {
+	var results []Analysis
+	for result := range RunPipeline(ctx, data) {
+		results = append(results, result)
+	}
+	return results
+}
+
+func generator[T any](ctx context.Context, data []T) <-chan T {
+	out := make(chan T)
 	go func() {
 		defer close(out)
-		for data := range input {
-			// Simulate analysis work
-			time.Sleep(40 * time.Millisecond)
-			
-			wordCount := len(strings.Fields(data))
-			result := fmt.Sprintf("%s (words: %d, length: %d)", data, wordCount, len(data))
-			out <- result
+		for _, item := range data {
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return out
-}
\ No newline at end of file
+}
+
+// pipelineInput is the raw text fed through the pipeline by the demos.
+var pipelineInput = []string{
+	"  Hello World!!!  ",
+	"  Go is AWESOME  ",
+	"  Concurrency ROCKS!!!  ",
+	"  Programming is FUN  ",
+	"  Pipelines are COOL  ",
+	"  Channels are GREAT  ",
+	"  Goroutines RULE  ",
+	"  Synchronization MATTERS  ",
+}
+
+// textStages holds the work done by each stage of the text pipeline.
+type textStages struct {
+	clean     func(string) string
+	transform func(string) string
+	analyze   func(string) Analysis
+}
+
+// demoTextStages is the text pipeline payload used by the demos, with a
+// simulated delay in front of each stage's real work.
+var demoTextStages = textStages{
+	clean:     simulateWork(50*time.Millisecond, cleanText),
+	transform: simulateWork(30*time.Millisecond, transformText),
+	analyze:   simulateWork(40*time.Millisecond, analyzeText),
+}
+
+// simulateWork wraps fn so every call first sleeps for d.
+func simulateWork[O any](d time.Duration, fn func(string) O) func(string) O {
+	return func(data string) O {
+		time.Sleep(d)
+		return fn(data)
+	}
+}
+
+// textPipeline chains the three text stages together, each with an output
+// buffer of the given size. Items left blank by cleaning are dropped.
+func textPipeline(buffer int, stages textStages) Stage[string, Analysis] {
+	// Stage 1: Clean data (trim whitespace, remove extra punctuation)
+	// Stage 2: Transform data (convert to lowercase, add prefix)
+	// Stage 3: Analyze data (count words, measure length)
+	return Chain(Chain(Chain(
+		cleanStage(buffer, stages.clean),
+		dropBlankStage()),
+		transformStage(buffer, stages.transform)),
+		analyzeStage(buffer, stages.analyze))
+}
+
+func dropBlankStage() Stage[string, string] {
+	return func(ctx context.Context, in <-chan string) <-chan string {
+		return Filter(ctx, in, hasText)
+	}
+}
+
+// hasText reports whether s contains anything other than whitespace.
+func hasText(s string) bool {
+	return strings.TrimSpace(s) != ""
+}
+
+// parallelTextPipeline is textPipeline with every stage widened to workers
+// goroutines.
+func parallelTextPipeline(workers int, stages textStages) Stage[string, Analysis] {
+	return Chain(Chain(
+		ParallelStage(workers, stages.clean),
+		ParallelStage(workers, stages.transform)),
+		ParallelStage(workers, stages.analyze))
+}
+
+func cleanStage(buffer int, clean func(string) string) Stage[string, string] {
+	return BufferedMapStage(buffer, clean)
+}
+
+func transformStage(buffer int, transform func(string) string) Stage[string, string] {
+	return BufferedMapStage(buffer, transform)
+}
+
+func analyzeStage(buffer int, analyze func(string) Analysis) Stage[string, Analysis] {
+	return BufferedMapStage(buffer, analyze)
+}
+
+func cleanText(data string) string {
+	cleaned := strings.TrimSpace(data)
+	return strings.ReplaceAll(cleaned, "!!!", "!")
+}
+
+// validateText rejects input that has no letters left after cleaning.
+func validateText(data string) (string, error) {
+	if !strings.ContainsFunc(data, unicode.IsLetter) {
+		return "", fmt.Errorf("malformed input %q: no words to process", data)
+	}
+	return data, nil
+}
+
+func transformText(data string) string {
+	return "processed: " + strings.ToLower(data)
+}
+
+// Analysis is what the analyze stage reports for one item of text.
+type Analysis struct {
+	Text   string
+	Words  int
+	Length int
+}
+
+// String formats the analysis the way the demos print it.
+func (a Analysis) String() string {
+	return fmt.Sprintf("%s (words: %d, length: %d)", a.Text, a.Words, a.Length)
+}
+
+func analyzeText(data string) Analysis {
+	return Analysis{Text: data, Words: len(strings.Fields(data)), Length: len(data)}
+}
+
+// PipelineSummary aggregates the statistics reported by the analyze stage.
+type PipelineSummary struct {
+	Items         int
+	TotalWords    int
+	AverageLength float64
+	Longest       string
+}
+
+// SummarizeResults totals the word counts and lengths of analyzed pipeline
+// results. Longest holds the text of the longest item.
+func SummarizeResults(results []Analysis) PipelineSummary {
+	summary := PipelineSummary{Items: len(results)}
+	var totalLength, longest int
+	for _, result := range results {
+		summary.TotalWords += result.Words
+		totalLength += result.Length
+		if result.Length > longest {
+			longest = result.Length
+			summary.Longest = result.Text
+		}
+	}
+	if summary.Items > 0 {
+		summary.AverageLength = float64(totalLength) / float64(summary.Items)
+	}
+	return summary
+}
@@ -0,0 +1,423 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// plainTextStages is the text pipeline payload without the simulated delays.
+var plainTextStages = textStages{
+	clean:     cleanText,
+	transform: transformText,
+	analyze:   analyzeText,
+}
+
+func TestChainAndMapStage(t *testing.T) {
+	double := MapStage(func(n int) int { return n * 2 })
+	toString := MapStage(strconv.Itoa)
+	stage := Chain(Chain(double, double), toString)
+
+	got := drain(stage(context.Background(), generator(context.Background(), []int{1, 2, 3})))
+	want := []string{"4", "8", "12"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildPipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		stages []func(string) string
+		want   []string
+	}{
+		{"no stages", nil, []string{" a ", " b "}},
+		{"in order", []func(string) string{cleanText, transformText}, []string{"processed: a", "processed: b"}},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		got := drain(BuildPipeline(ctx, generator(ctx, []string{" a ", " b "}), tt.stages...))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBufferedMapStage(t *testing.T) {
+	for _, buffer := range []int{0, 1, 4} {
+		out := BufferedMapStage(buffer, square)(context.Background(), generator(context.Background(), []int{1, 2, 3}))
+		if cap(out) != buffer {
+			t.Errorf("buffer %d: output capacity %d", buffer, cap(out))
+		}
+		if got := drain(out); !slices.Equal(got, []int{1, 4, 9}) {
+			t.Errorf("buffer %d: got %v", buffer, got)
+		}
+	}
+}
+
+// alternatingRun times a stage that is fast for the first half of its items
+// and slow for the rest, read by a consumer that is slow for the first half
+// and fast for the rest. Only a buffer lets the two slow halves overlap.
+func alternatingRun(buffer int) time.Duration {
+	const items, slow = 8, 10 * time.Millisecond
+	input := make([]int, 2*items)
+	for i := range input {
+		input[i] = i
+	}
+	stage := BufferedMapStage(buffer, func(n int) int {
+		if n >= items {
+			time.Sleep(slow)
+		}
+		return n
+	})
+
+	start := time.Now()
+	for n := range stage(context.Background(), generator(context.Background(), input)) {
+		if n < items {
+			time.Sleep(slow)
+		}
+	}
+	return time.Since(start)
+}
+
+func TestBufferedMapStageOverlapsSlowConsumer(t *testing.T) {
+	unbuffered, buffered := alternatingRun(0), alternatingRun(8)
+	if buffered > unbuffered*3/4 {
+		t.Errorf("buffer 8 took %v, buffer 0 took %v; want the buffer to save time", buffered, unbuffered)
+	}
+}
+
+func TestParallelStage(t *testing.T) {
+	expectPanic(t, "ParallelStage with no workers", func() { ParallelStage(0, square) })
+
+	for _, workers := range []int{1, 3, 10} {
+		input := []int{1, 2, 3, 4, 5, 6}
+		got := drain(ParallelStage(workers, square)(context.Background(), generator(context.Background(), input)))
+		slices.Sort(got)
+		if want := []int{1, 4, 9, 16, 25, 36}; !slices.Equal(got, want) {
+			t.Errorf("%d workers: got %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestStagesStopOnCancel(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage Stage[int, int]
+	}{
+		{"MapStage", MapStage(square)},
+		{"ParallelStage", ParallelStage(3, square)},
+		{"InstrumentedStage", func() Stage[int, int] { s, _ := InstrumentedStage("square", square); return s }()},
+		{"Filter", func(ctx context.Context, in <-chan int) <-chan int {
+			return Filter(ctx, in, func(int) bool { return true })
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Keep feeding input after ctx is cancelled, so the stage has to
+			// notice ctx itself rather than wait for its input to close.
+			ctx, cancel := context.WithCancel(context.Background())
+			stop := make(chan struct{})
+			defer close(stop)
+			in := make(chan int)
+			go func() {
+				for i := 0; ; i++ {
+					select {
+					case in <- i:
+					case <-stop:
+						return
+					}
+				}
+			}()
+
+			out := tt.stage(ctx, in)
+			<-out
+			cancel()
+
+			done := make(chan struct{})
+			go func() {
+				drain(out)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("output not closed after cancel")
+			}
+		})
+	}
+}
+
+func TestInstrumentedStage(t *testing.T) {
+	stage, metrics := InstrumentedStage("slow", func(n int) int {
+		time.Sleep(5 * time.Millisecond)
+		return n
+	})
+	drain(stage(context.Background(), generator(context.Background(), []int{1, 2, 3, 4})))
+
+	if metrics.Name != "slow" || metrics.Items != 4 {
+		t.Errorf("metrics = %+v, want 4 items for slow", *metrics)
+	}
+	if metrics.Busy < 20*time.Millisecond {
+		t.Errorf("Busy = %v, want at least 4x5ms", metrics.Busy)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	got := drain(Merge(ctx, generator(ctx, []int{1, 2}), generator(ctx, []int{3}), generator(ctx, []int{4, 5})))
+	slices.Sort(got)
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTee(t *testing.T) {
+	ctx := context.Background()
+	input := []string{"a", "b", "c"}
+	outs := Tee(ctx, generator(ctx, input), 3)
+
+	results := make([][]string, len(outs))
+	done := make(chan int)
+	for i, out := range outs {
+		go func() {
+			results[i] = drain(out)
+			done <- i
+		}()
+	}
+	for range outs {
+		<-done
+	}
+	for i, got := range results {
+		if !slices.Equal(got, input) {
+			t.Errorf("output %d got %v, want %v", i, got, input)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		keep func(int) bool
+		want []int
+	}{
+		{"evens", func(n int) bool { return n%2 == 0 }, []int{2, 4, 6}},
+		{"none", func(int) bool { return false }, nil},
+		{"all", func(int) bool { return true }, []int{1, 2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		got := drain(Filter(ctx, generator(ctx, []int{1, 2, 3, 4, 5, 6}), tt.keep))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []int
+	}{
+		{0, nil},
+		{2, []int{1, 4}},
+		{10, []int{1, 4, 9, 16, 25, 36, 49, 64}},
+	}
+	for _, tt := range tests {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		upstream := MapStage(square)(ctx, generator(ctx, []int{1, 2, 3, 4, 5, 6, 7, 8}))
+
+		got := drain(Take(ctx, upstream, tt.n, cancel))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Take(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+		if ctx.Err() == nil {
+			t.Errorf("Take(%d) did not cancel upstream", tt.n)
+		}
+		waitFor(t, "the upstream stages to exit", func() bool { return runtime.NumGoroutine() <= before })
+	}
+}
+
+func TestTakeConsumerStopsEarly(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	out := Take(ctx, generator(ctx, []int{1, 2, 3, 4, 5, 6, 7, 8}), 5, cancel)
+
+	<-out
+	cancel() // stop reading after one value
+	waitFor(t, "Take and its upstream to exit", func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	got := drain(Batch(ctx, generator(ctx, []int{1, 2, 3, 4, 5, 6, 7}), 3, time.Minute))
+	want := [][]int{{1, 2, 3}, {4, 5, 6}, {7}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("batch %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBatchFlushesAfterMaxWait(t *testing.T) {
+	in := make(chan int)
+	defer close(in)
+	out := Batch(context.Background(), in, 10, 10*time.Millisecond)
+
+	in <- 1
+	select {
+	case batch := <-out:
+		if !slices.Equal(batch, []int{1}) {
+			t.Errorf("got %v, want [1]", batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a partial batch was not flushed after maxWait")
+	}
+}
+
+func TestTryStageSkipsFailedItems(t *testing.T) {
+	errNegative := errors.New("negative")
+	var calls atomic.Int64
+	parse := TryStage(func(n int) (int, error) {
+		if n < 0 {
+			return 0, errNegative
+		}
+		return n, nil
+	})
+	double := TryStage(func(n int) (int, error) {
+		calls.Add(1)
+		return n * 2, nil
+	})
+
+	ctx := context.Background()
+	items := []Item[int]{{Value: 1}, {Value: -1}, {Value: 3}}
+	got := drain(Chain(Chain(parse, double), MapItems(strconv.Itoa))(ctx, generator(ctx, items)))
+
+	want := []Item[string]{{Value: "2"}, {Err: errNegative}, {Value: "6"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Value != want[i].Value || !errors.Is(got[i].Err, want[i].Err) {
+			t.Errorf("item %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("later stage called %d times, want the failed item skipped", calls.Load())
+	}
+}
+
+func TestValidateText(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"hello", false},
+		{"!!!", true},
+		{"", true},
+		{"42 apples", false},
+	}
+	for _, tt := range tests {
+		if _, err := validateText(tt.in); (err != nil) != tt.wantErr {
+			t.Errorf("validateText(%q) error = %v, want error: %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTextPipelines(t *testing.T) {
+	input := []string{"  Hello World!!!  ", "   ", "  Go is FUN  "}
+	want := []Analysis{
+		{Text: "processed: hello world!", Words: 3, Length: 23},
+		{Text: "processed: go is fun", Words: 4, Length: 20},
+	}
+
+	tests := []struct {
+		name    string
+		stage   Stage[string, Analysis]
+		ordered bool
+	}{
+		{"textPipeline", textPipeline(0, plainTextStages), true},
+		{"buffered textPipeline", textPipeline(2, plainTextStages), true},
+		{"parallelTextPipeline", parallelTextPipeline(3, plainTextStages), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			got := drain(tt.stage(ctx, generator(ctx, input)))
+			want := slices.Clone(want)
+			if !tt.ordered {
+				// The parallel pipeline has no blank filter and finishes items
+				// in any order.
+				got = slices.DeleteFunc(got, func(a Analysis) bool { return a.Words == 1 })
+				byText := func(a, b Analysis) int { return strings.Compare(a.Text, b.Text) }
+				slices.SortFunc(got, byText)
+				slices.SortFunc(want, byText)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestProcessTexts(t *testing.T) {
+	got := ProcessTexts(context.Background(), []string{" A b ", "  ", "C!!!"})
+	want := []Analysis{
+		{Text: "processed: a b", Words: 3, Length: 14},
+		{Text: "processed: c!", Words: 2, Length: 13},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := ProcessTexts(ctx, pipelineInput); len(got) == len(pipelineInput) {
+		t.Errorf("a cancelled ProcessTexts still processed every item")
+	}
+}
+
+func TestSummarizeResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Analysis
+		want    PipelineSummary
+	}{
+		{"empty", nil, PipelineSummary{}},
+		{
+			name: "several",
+			results: []Analysis{
+				{Text: "a b", Words: 2, Length: 3},
+				{Text: "longer one", Words: 2, Length: 10},
+				{Text: "c", Words: 1, Length: 1},
+			},
+			want: PipelineSummary{Items: 3, TotalWords: 5, AverageLength: 14.0 / 3, Longest: "longer one"},
+		},
+	}
+	for _, tt := range tests {
+		if got := SummarizeResults(tt.results); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineComparisonCancelled(t *testing.T) {
+	quietOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := PipelineComparison(ctx)
+	if !summary.Cancelled || summary.Sequential != 0 {
+		t.Errorf("summary = %+v, want cancelled before the sequential run", summary)
+	}
+}
@@ -1,113 +1,651 @@
 package patterns
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func RateLimiter() {
-	fmt.Println("=== Rate Limiter Pattern ===")
-	fmt.Println("Controlling the rate of operations to prevent overwhelming resources")
-	fmt.Println("Use case: API client making requests with rate limiting to avoid being blocked")
-	fmt.Println()
+// Limiter is what code needs from a rate limiter. Depending on it rather than
+// on *RateLimiter lets tests use a fake and lets a distributed limiter be
+// swapped in later.
+type Limiter interface {
+	Allow() bool
+	Wait(ctx context.Context) error
+}
+
+var _ Limiter = (*RateLimiter)(nil)
+
+// ErrLimiterStopped is returned by Wait once the limiter has been stopped.
+var ErrLimiterStopped = errors.New("rate limiter stopped")
+
+// RateLimiter is a token bucket: it holds up to burst tokens and a background
+// goroutine adds one token every 1/rate seconds. Each request spends a token.
+type RateLimiter struct {
+	rate       float64
+	burst      int
+	tokens     int
+	nextRefill time.Time
+	available  chan struct{} // closed and replaced whenever a token is added
+	done       chan struct{} // closed to stop the refill goroutine
+	stopped    bool
+	stopOnce   sync.Once
+	pending    atomic.Int64  // goroutines currently blocked in Wait
+	jitter     time.Duration // max random delay Wait adds before taking a token
+	rng        *rand.Rand    // nil uses the global math/rand source
+	reschedule chan struct{} // tells the refill goroutine nextRefill moved
+	created    time.Time
+	warmup     time.Duration // ramp-up period from coldRateFactor*rate to rate
+	mutex      sync.Mutex
+}
+
+// coldRateFactor is the fraction of the target rate a warming-up limiter
+// starts at.
+const coldRateFactor = 1.0 / 3
+
+// NewRateLimiter creates a limiter that allows rate requests per second on
+// average, with bursts of up to burst requests. The bucket starts full.
+// Rates below one are fine: 0.4 grants a token every 2.5 seconds. It panics
+// if rate is not positive or burst is less than one.
+func NewRateLimiter(rate float64, burst int, opts ...LimiterOption) *RateLimiter {
+	return newRateLimiter(rate, burst, burst, 0, opts)
+}
+
+// NewRateLimiterWarmup creates a limiter for a cold downstream. It starts
+// with an empty bucket at a third of target and ramps linearly up to target
+// over the warmup period, in the spirit of Guava's SmoothWarmingUp.
+func NewRateLimiterWarmup(target float64, burst int, warmup time.Duration, opts ...LimiterOption) *RateLimiter {
+	return newRateLimiter(target, burst, 0, warmup, opts)
+}
+
+// LimiterOption configures a RateLimiter when it is created.
+type LimiterOption func(*RateLimiter)
+
+// WithLimiterJitter is the option form of SetJitter.
+func WithLimiterJitter(max time.Duration) LimiterOption {
+	return func(rl *RateLimiter) {
+		rl.jitter = max
+	}
+}
+
+// WithLimiterRandSource is the option form of SetRandSource.
+func WithLimiterRandSource(rng *rand.Rand) LimiterOption {
+	return func(rl *RateLimiter) {
+		rl.rng = rng
+	}
+}
+
+func newRateLimiter(rate float64, burst, tokens int, warmup time.Duration, opts []LimiterOption) *RateLimiter {
+	checkRate(rate)
+	checkBurst(burst)
+
+	rl := &RateLimiter{
+		rate:       rate,
+		burst:      burst,
+		tokens:     tokens,
+		available:  make(chan struct{}),
+		done:       make(chan struct{}),
+		reschedule: make(chan struct{}, 1),
+		created:    time.Now(),
+		warmup:     warmup,
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	rl.nextRefill = rl.created.Add(rl.interval())
+	go rl.refill()
+	return rl
+}
+
+// Allow spends a token if one is available and reports whether it did.
+// It never blocks.
+func (rl *RateLimiter) Allow() bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.stopped {
+		return false
+	}
+	if rl.tokens > 0 {
+		rl.tokens--
+		return true
+	}
+	return false
+}
+
+// Wait blocks until a token is available or ctx is done. Once ctx is done it
+// returns ctx.Err() straight away without spending a token, even if one is
+// available. Waiting parks on a channel that the refill goroutine closes, so
+// Wait starts no goroutines or timers that could outlive it.
+//
+// With jitter enabled Wait first sleeps a random extra delay, so clients
+// limited at the same rate don't fall into step with each other.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if err := rl.sleepJitter(ctx); err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rl.mutex.Lock()
+		if rl.stopped {
+			rl.mutex.Unlock()
+			return ErrLimiterStopped
+		}
+		if rl.tokens > 0 {
+			rl.tokens--
+			rl.mutex.Unlock()
+			return nil
+		}
+		available := rl.available
+		rl.mutex.Unlock()
+
+		if err := rl.block(ctx, available); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepJitter waits a random duration in [0, jitter) before Wait competes for
+// a token, returning early if ctx is done.
+func (rl *RateLimiter) sleepJitter(ctx context.Context) error {
+	rl.mutex.Lock()
+	var delay time.Duration
+	if rl.jitter > 0 {
+		if rl.rng != nil {
+			delay = time.Duration(rl.rng.Int63n(int64(rl.jitter)))
+		} else {
+			delay = time.Duration(rand.Int63n(int64(rl.jitter)))
+		}
+	}
+	rl.mutex.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// block parks the caller until available is closed, counting it in Pending
+// meanwhile.
+func (rl *RateLimiter) block(ctx context.Context, available <-chan struct{}) error {
+	rl.pending.Add(1)
+	defer rl.pending.Add(-1)
+
+	select {
+	case <-available:
+		return nil
+	case <-rl.done:
+		return ErrLimiterStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending returns how many goroutines are currently blocked in Wait, e.g. to
+// log how many requests were still queued at shutdown.
+func (rl *RateLimiter) Pending() int {
+	return int(rl.pending.Load())
+}
+
+// SetRate changes the refill rate. The pending refill is rescheduled as if
+// the new rate had applied since the last one; tokens already handed out or
+// sitting in the bucket are kept. Like NewRateLimiter it panics if rate is
+// not positive.
+func (rl *RateLimiter) SetRate(rate float64) {
+	checkRate(rate)
+
+	rl.mutex.Lock()
+	lastRefill := rl.nextRefill.Add(-rl.interval())
+	rl.rate = rate
+	rl.nextRefill = lastRefill.Add(rl.interval())
+	rl.mutex.Unlock()
+
+	select {
+	case rl.reschedule <- struct{}{}:
+	default:
+	}
+}
+
+// SetBurst changes the bucket size. Shrinking it discards tokens that no
+// longer fit. It panics if burst is less than one.
+func (rl *RateLimiter) SetBurst(burst int) {
+	checkBurst(burst)
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.burst = burst
+	if rl.tokens > burst {
+		rl.tokens = burst
+	}
+}
+
+// SetJitter makes Wait add a random delay in [0, max) on top of the normal
+// wait. Zero (the default) disables jitter. Allow and Reserve are unaffected.
+func (rl *RateLimiter) SetJitter(max time.Duration) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.jitter = max
+}
+
+// SetRandSource replaces the random source used for jitter, mainly so tests
+// can use a seeded generator.
+func (rl *RateLimiter) SetRandSource(rng *rand.Rand) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.rng = rng
+}
+
+// Reservation is the result of Reserve. When OK it holds a token that can be
+// handed back with Cancel; otherwise Delay says how long until one is due.
+type Reservation struct {
+	ok       bool
+	stopped  bool // the limiter was stopped and will never grant a token
+	delay    time.Duration
+	limiter  *RateLimiter
+	canceled bool
+}
+
+// Reserve takes a token if one is available right now, without blocking.
+// If the bucket is empty nothing is consumed and the reservation reports how
+// long until the next refill, so callers can decide whether to wait or drop
+// the request.
+func (rl *RateLimiter) Reserve() *Reservation {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.stopped {
+		return &Reservation{stopped: true, limiter: rl}
+	}
+	if rl.tokens > 0 {
+		rl.tokens--
+		return &Reservation{ok: true, limiter: rl}
+	}
+
+	delay := time.Until(rl.nextRefill)
+	if delay < 0 {
+		delay = 0
+	}
+	return &Reservation{delay: delay, limiter: rl}
+}
+
+// OK reports whether the reservation holds a token.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay is how long until the next token is due; zero when OK.
+func (r *Reservation) Delay() time.Duration {
+	return r.delay
+}
+
+// Cancel returns an unused token to the limiter. It does nothing for a
+// reservation that isn't OK or was already cancelled.
+func (r *Reservation) Cancel() {
+	if !r.ok || r.canceled {
+		return
+	}
+	r.canceled = true
+
+	r.limiter.mutex.Lock()
+	defer r.limiter.mutex.Unlock()
+	r.limiter.addTokenLocked()
+}
+
+// addTokenLocked puts a token in the bucket, if there is room, and wakes any
+// waiters. The caller must hold the mutex.
+func (rl *RateLimiter) addTokenLocked() {
+	if !rl.stopped && rl.tokens < rl.burst {
+		rl.tokens++
+		close(rl.available)
+		rl.available = make(chan struct{})
+	}
+}
+
+// checkRate panics on a rate that would never refill the bucket (or would
+// divide by zero when computing the refill interval).
+func checkRate(rate float64) {
+	if !(rate > 0) {
+		panic(fmt.Sprintf("patterns: rate limiter rate must be positive, got %v", rate))
+	}
+}
+
+// checkBurst panics on a bucket that could never hold a token.
+func checkBurst(burst int) {
+	if burst < 1 {
+		panic(fmt.Sprintf("patterns: rate limiter burst must be at least 1, got %d", burst))
+	}
+}
+
+// interval is the time between refills at the current effective rate.
+func (rl *RateLimiter) interval() time.Duration {
+	return time.Duration(float64(time.Second) / rl.effectiveRate())
+}
+
+// effectiveRate is the configured rate, or while warming up a rate
+// interpolated between the cold starting rate and the configured one.
+func (rl *RateLimiter) effectiveRate() float64 {
+	elapsed := time.Since(rl.created)
+	if rl.warmup <= 0 || elapsed >= rl.warmup {
+		return rl.rate
+	}
+
+	cold := rl.rate * coldRateFactor
+	progress := float64(elapsed) / float64(rl.warmup)
+	return cold + (rl.rate-cold)*progress
+}
+
+func (rl *RateLimiter) refill() {
+	for {
+		rl.mutex.Lock()
+		wait := time.Until(rl.nextRefill)
+		rl.mutex.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-rl.done:
+			timer.Stop()
+			return
+		case <-rl.reschedule:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		rl.mutex.Lock()
+		rl.addTokenLocked()
+		rl.nextRefill = rl.nextRefill.Add(rl.interval())
+		rl.mutex.Unlock()
+	}
+}
+
+// Stop ends the refill goroutine; call it once the limiter is no longer
+// needed so the goroutine doesn't leak. A stopped limiter refuses every
+// request: Allow returns false and Wait, including callers already blocked
+// in it, returns ErrLimiterStopped. Stop is safe to call more than once.
+func (rl *RateLimiter) Stop() {
+	rl.stopOnce.Do(func() {
+		rl.mutex.Lock()
+		rl.stopped = true
+		rl.tokens = 0
+		rl.mutex.Unlock()
+		close(rl.done)
+	})
+}
+
+// Throttle forwards jobs to the returned channel no faster than limiter
+// allows, closing it once jobs is closed and drained. Feed the result to a
+// worker pool to cap the rate at which work is submitted. If the limiter
+// stops granting tokens, as a stopped RateLimiter does, Throttle stops
+// forwarding and closes the output early; jobs left in the input are not
+// read.
+func Throttle[T any](limiter Limiter, jobs <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for job := range jobs {
+			if err := limiter.Wait(context.Background()); err != nil {
+				return
+			}
+			out <- job
+		}
+	}()
+	return out
+}
+
+// RateLimiterDemo runs the interactive rate limiter menu. Cancelling ctx
+// stops the rate-limited comparison early and leaves the menu.
+func RateLimiterDemo(ctx context.Context) {
+	rateLimiterHeader()
+	settings := defaultRLDemoSettings()
+	for {
+		console.Println("Rate Limiter Demo Options:")
+		console.Println("1. 🚦 Rate-limited vs unlimited (comparison)")
+		console.Println("2. 🪣 Token bucket vs leaky bucket")
+		console.Println("3. ⚙️  Configure limiter settings")
+		console.Println("0. Back to main menu")
+		console.Print("Select demo (0-3): ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+		console.Println()
+
+		switch choice {
+		case 1:
+			runRateLimiterComparison(ctx, settings)
+		case 2:
+			runBucketComparisonDemo()
+		case 3:
+			settings = configureRLDemoSettings(settings)
+		case 0:
+			return
+		default:
+			console.Println("Invalid choice. Please try again.")
+			console.Println()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		console.Println("\nPress Enter to continue...")
+		fmt.Scanf("\n")
+		console.Println()
+	}
+}
+
+// RateLimiterComparison runs the rate-limited vs unlimited comparison with
+// the default settings, without asking for input. Cancelling ctx cuts it
+// short.
+func RateLimiterComparison(ctx context.Context) RunSummary {
+	rateLimiterHeader()
+	return runRateLimiterComparison(ctx, defaultRLDemoSettings())
+}
+
+func rateLimiterHeader() {
+	console.Println("=== Rate Limiter Pattern ===")
+	console.Println("Controlling the rate of operations to prevent overwhelming resources")
+	console.Println("Use case: API client making requests with rate limiting to avoid being blocked")
+	console.Println()
+}
+
+// rlDemoSettings holds the user's tuning for the rate-limited comparison.
+type rlDemoSettings struct {
+	rate     float64
+	burst    int
+	requests int
+}
+
+func defaultRLDemoSettings() rlDemoSettings {
+	return rlDemoSettings{rate: 3, burst: 2, requests: 10}
+}
+
+func configureRLDemoSettings(s rlDemoSettings) rlDemoSettings {
+	console.Println("⚙️  === Configure Rate Limiter ===")
+	console.Println("Press Enter to keep the current value")
+	console.Println()
+
+	s.rate = promptFloat("Requests per second", s.rate)
+	s.burst = promptInt("Burst size", s.burst, 1)
+	s.requests = promptInt("Number of requests", s.requests, 1)
+
+	console.Printf("\n🔧 Comparison will send %d requests at %g/sec with bursts of %d\n", s.requests, s.rate, s.burst)
+	return s
+}
+
+func runRateLimiterComparison(ctx context.Context, settings rlDemoSettings) RunSummary {
+	requests := apiRequests(settings.requests)
 
 	// Run concurrent version
-	fmt.Println("Running CONCURRENT (rate-limited) version...")
+	console.Detailln("Running CONCURRENT (rate-limited) version...")
 	concurrentStart := time.Now()
-	runRateLimiterConcurrent()
+	stats := runRateLimiterConcurrent(ctx, settings.rate, settings.burst, requests)
 	concurrentDuration := time.Since(concurrentStart)
 
-	fmt.Printf("\nCONCURRENT (rate-limited) version took: %v\n\n", concurrentDuration)
+	summary := RunSummary{
+		Pattern:    "rate-limiter",
+		Completed:  stats.BurstServed + stats.RateLimited,
+		Concurrent: concurrentDuration,
+		Details: map[string]any{
+			"rate":        settings.rate,
+			"burst":       settings.burst,
+			"burstServed": stats.BurstServed,
+			"rateLimited": stats.RateLimited,
+			"totalWaitMs": milliseconds(stats.TotalWait),
+		},
+	}
+	if cancelled(ctx, &summary) {
+		return summary
+	}
+
+	console.Printf("Completed %d rate-limited requests\n", stats.BurstServed+stats.RateLimited)
+	console.Printf("📊 %d served from burst, %d rate-limited (total wait: %v)\n",
+		stats.BurstServed, stats.RateLimited, stats.TotalWait.Round(time.Millisecond))
+	console.Printf("\nCONCURRENT (rate-limited) version took: %v\n\n", concurrentDuration)
 
 	// Run sequential version for comparison
-	fmt.Println("Running SEQUENTIAL (unlimited) version for comparison...")
+	console.Detailln("Running SEQUENTIAL (unlimited) version for comparison...")
 	sequentialStart := time.Now()
-	runRateLimiterSequential()
-	sequentialDuration := time.Since(sequentialStart)
+	runRateLimiterSequential(ctx, requests)
+	summary.Sequential = time.Since(sequentialStart)
+	if cancelled(ctx, &summary) {
+		return summary
+	}
 
-	fmt.Printf("\nSEQUENTIAL (unlimited) version took: %v\n", sequentialDuration)
-	fmt.Printf("Note: Rate limiter adds controlled delay vs unlimited requests\n")
-	fmt.Printf("Rate limiter prevents resource exhaustion and API blocks!\n\n")
+	console.Printf("\nSEQUENTIAL (unlimited) version took: %v\n", summary.Sequential)
+	console.Printf("Note: Rate limiter adds controlled delay vs unlimited requests\n")
+	console.Printf("Rate limiter prevents resource exhaustion and API blocks!\n\n")
+	return summary
 }
 
-func runRateLimiterConcurrent() {
-	
-	// Create rate limiter: 3 requests per second
-	rateLimiter := time.NewTicker(time.Second / 3)
-	defer rateLimiter.Stop()
+func runBucketComparisonDemo() {
+	console.Println("🪣 === Token Bucket vs Leaky Bucket ===")
+	console.Println("8 requests arrive at once; both limiters allow 4 requests/second")
+	console.Println()
+
+	const numRequests = 8
 
-	// Burst limiter: allow up to 2 requests immediately
-	burstLimiter := make(chan struct{}, 2)
-	for i := 0; i < cap(burstLimiter); i++ {
-		burstLimiter <- struct{}{}
+	console.Println("Token bucket (burst 4) - bursts first, then paces:")
+	tokenBucket := NewRateLimiter(4, 4)
+	defer tokenBucket.Stop()
+	start := time.Now()
+	for i := 1; i <= numRequests; i++ {
+		tokenBucket.Wait(context.Background())
+		console.Detailf("Request %d: admitted at %v\n", i, time.Since(start).Round(time.Millisecond))
 	}
 
-	// Refill burst limiter periodically
-	go func() {
-		ticker := time.NewTicker(time.Second / 3)
-		defer ticker.Stop()
-		for range ticker.C {
-			select {
-			case burstLimiter <- struct{}{}:
-			default:
-			}
-		}
-	}()
+	console.Println("\nLeaky bucket (capacity 8) - evenly spaced output:")
+	leakyBucket := NewLeakyBucket(4, numRequests)
+	defer leakyBucket.Stop()
+	start = time.Now()
+	for i := 0; i < numRequests; i++ {
+		leakyBucket.Submit()
+	}
+	for i := 1; i <= numRequests; i++ {
+		released := <-leakyBucket.Drained()
+		console.Detailf("Request %d: released at %v\n", i, released.Sub(start).Round(time.Millisecond))
+	}
+
+	console.Println("\n🚦 Token bucket allows bursts up to its size")
+	console.Println("💧 Leaky bucket smooths traffic to a strictly even rate")
+}
 
-	// Simulate API requests
-	requests := []string{
-		"GET /api/users",
-		"POST /api/users",
-		"GET /api/posts",
-		"PUT /api/users/1",
-		"DELETE /api/posts/5",
-		"GET /api/comments",
-		"POST /api/posts",
-		"GET /api/analytics",
-		"PUT /api/settings",
-		"GET /api/dashboard",
-	}
-
-	var completed int
+// RateLimiterStats describes how a batch of requests got through the limiter:
+// immediately with a token already in the bucket, or after waiting for one.
+type RateLimiterStats struct {
+	BurstServed int
+	RateLimited int
+	TotalWait   time.Duration
+}
+
+// sampleAPIRequests are the simulated API calls the demo cycles through.
+var sampleAPIRequests = []string{
+	"GET /api/users",
+	"POST /api/users",
+	"GET /api/posts",
+	"PUT /api/users/1",
+	"DELETE /api/posts/5",
+	"GET /api/comments",
+	"POST /api/posts",
+	"GET /api/analytics",
+	"PUT /api/settings",
+	"GET /api/dashboard",
+}
+
+// apiRequests returns n simulated requests, repeating the samples as needed.
+func apiRequests(n int) []string {
+	requests := make([]string, n)
+	for i := range requests {
+		requests[i] = sampleAPIRequests[i%len(sampleAPIRequests)]
+	}
+	return requests
+}
+
+func runRateLimiterConcurrent(ctx context.Context, rate float64, burst int, requests []string) RateLimiterStats {
+	
+	// Create rate limiter: rate requests per second, bursts of up to burst
+	limiter := NewRateLimiter(rate, burst)
+	defer limiter.Stop()
+
+	return sendRateLimited(ctx, limiter, requests)
+}
+
+// sendRateLimited sends requests through limiter, stopping early if ctx is
+// cancelled while waiting for a token or between requests.
+func sendRateLimited(ctx context.Context, limiter Limiter, requests []string) RateLimiterStats {
+	var stats RateLimiterStats
 	for _, request := range requests {
-		// Wait for burst token or rate limit
-		select {
-		case <-burstLimiter:
-			// Use burst token immediately
-		default:
-			// Wait for rate limiter
-			<-rateLimiter.C
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Use a burst token immediately or wait for the next refill
+		if limiter.Allow() {
+			stats.BurstServed++
+		} else {
+			waitStart := time.Now()
+			if err := limiter.Wait(ctx); err != nil {
+				break
+			}
+			stats.TotalWait += time.Since(waitStart)
+			stats.RateLimited++
 		}
 
 		// Simulate API call processing time
-		time.Sleep(50 * time.Millisecond)
-		completed++
+		if sleepContext(ctx, 50*time.Millisecond) != nil {
+			break
+		}
 		_ = request // Use the request variable
 	}
 
-	fmt.Printf("Completed %d rate-limited requests\n", completed)
+	return stats
 }
 
-func runRateLimiterSequential() {
-	requests := []string{
-		"GET /api/users",
-		"POST /api/users",
-		"GET /api/posts",
-		"PUT /api/users/1",
-		"DELETE /api/posts/5",
-		"GET /api/comments",
-		"POST /api/posts",
-		"GET /api/analytics",
-		"PUT /api/settings",
-		"GET /api/dashboard",
-	}
-
+func runRateLimiterSequential(ctx context.Context, requests []string) {
 	for _, request := range requests {
+		if ctx.Err() != nil {
+			return
+		}
 		// Simulate API call processing time (same as concurrent)
 		time.Sleep(50 * time.Millisecond)
 		_ = request // Use the request variable
 	}
 
-	fmt.Printf("Completed %d unlimited requests\n", len(requests))
-	fmt.Println("⚠️  Warning: This approach might get blocked by API rate limits!")
+	console.Printf("Completed %d unlimited requests\n", len(requests))
+	console.Println("⚠️  Warning: This approach might get blocked by API rate limits!")
 }
\ No newline at end of file
@@ -0,0 +1,782 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLimiter grants Allow tokens until it runs out, then makes Wait return
+// waitErr.
+type fakeLimiter struct {
+	tokens  atomic.Int64
+	waitErr error
+	waits   atomic.Int64
+}
+
+func newFakeLimiter(tokens int, waitErr error) *fakeLimiter {
+	l := &fakeLimiter{waitErr: waitErr}
+	l.tokens.Store(int64(tokens))
+	return l
+}
+
+func (l *fakeLimiter) Allow() bool {
+	return l.tokens.Add(-1) >= 0
+}
+
+func (l *fakeLimiter) Wait(ctx context.Context) error {
+	l.waits.Add(1)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.waitErr
+}
+
+// measureRate spends limiter's burst, waits for one refill to line up with
+// it, then times n more Waits and returns the rate they were granted at.
+func measureRate(t *testing.T, limiter Limiter, n int) float64 {
+	t.Helper()
+	for limiter.Allow() {
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for range n {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return float64(n) / time.Since(start).Seconds()
+}
+
+// checkMeasuredRate fails the test unless got is close to want. Timers only
+// fire late, so the measured rate may fall well short of want but not exceed
+// it by much.
+func checkMeasuredRate(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if got < want*0.5 || got > want*1.2 {
+		t.Errorf("%s: measured %.1f/s, want about %v/s", what, got, want)
+	}
+}
+
+func TestNewRateLimiterValidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+	}{
+		{"zero rate", 0, 1},
+		{"negative rate", -2, 1},
+		{"NaN rate", math.NaN(), 1},
+		{"zero burst", 1, 0},
+		{"negative burst", 1, -1},
+	}
+	for _, tt := range tests {
+		expectPanic(t, "NewRateLimiter with "+tt.name, func() {
+			NewRateLimiter(tt.rate, tt.burst).Stop()
+		})
+	}
+}
+
+func TestRateLimiterAllowSpendsBurst(t *testing.T) {
+	tests := []struct {
+		burst int
+	}{{1}, {3}, {10}}
+	for _, tt := range tests {
+		rl := NewRateLimiter(0.1, tt.burst)
+		for i := 0; i < tt.burst; i++ {
+			if !rl.Allow() {
+				t.Errorf("burst %d: Allow %d refused", tt.burst, i+1)
+			}
+		}
+		if rl.Allow() {
+			t.Errorf("burst %d: Allow succeeded with an empty bucket", tt.burst)
+		}
+		rl.Stop()
+	}
+}
+
+func TestRateLimiterFractionalRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want time.Duration
+	}{
+		{0.4, 2500 * time.Millisecond},
+		{0.5, 2 * time.Second},
+		{4, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		rl := NewRateLimiter(tt.rate, 1)
+		if got := rl.interval(); got != tt.want {
+			t.Errorf("rate %v: interval = %v, want %v", tt.rate, got, tt.want)
+		}
+		rl.Stop()
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	rl := NewRateLimiter(100, 1)
+	defer rl.Stop()
+
+	if !rl.Allow() {
+		t.Fatal("Allow refused with a full bucket")
+	}
+	waitFor(t, "a refilled token", rl.Allow)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rl.Wait(ctx); err != nil {
+		t.Fatalf("Wait = %v, want a refilled token", err)
+	}
+}
+
+func TestRateLimiterWaitContext(t *testing.T) {
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		empty bool // spend the only token first
+		want  error
+	}{
+		{"cancelled with a token available", cancelledCtx, false, context.Canceled},
+		{"deadline while waiting", timeoutCtx, true, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter(0.1, 1)
+			defer rl.Stop()
+			if tt.empty {
+				rl.Allow()
+			}
+
+			start := time.Now()
+			if err := rl.Wait(tt.ctx); !errors.Is(err, tt.want) {
+				t.Fatalf("Wait = %v, want %v", err, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Errorf("Wait took %v to notice ctx", elapsed)
+			}
+			if !tt.empty && !rl.Allow() {
+				t.Errorf("Wait spent a token although ctx was already done")
+			}
+		})
+	}
+}
+
+func TestRateLimiterStop(t *testing.T) {
+	rl := NewRateLimiter(0.1, 1)
+	rl.Allow()
+
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- rl.Wait(context.Background())
+	}()
+	waitFor(t, "a blocked Wait", func() bool { return rl.Pending() == 1 })
+
+	rl.Stop()
+	rl.Stop() // safe to call twice
+
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrLimiterStopped) {
+			t.Errorf("blocked Wait = %v, want ErrLimiterStopped", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not release a blocked Wait")
+	}
+	if rl.Allow() {
+		t.Errorf("Allow succeeded after Stop")
+	}
+	if err := rl.Wait(context.Background()); !errors.Is(err, ErrLimiterStopped) {
+		t.Errorf("Wait after Stop = %v, want ErrLimiterStopped", err)
+	}
+	if r := rl.Reserve(); r.OK() {
+		t.Errorf("Reserve succeeded after Stop")
+	}
+}
+
+func TestRateLimiterStopEndsRefill(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for range 100 {
+		NewRateLimiter(10, 1).Stop()
+	}
+	waitFor(t, "the refill goroutines to exit", func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestRateLimiterPending(t *testing.T) {
+	const waiters = 5
+	tests := []struct {
+		name    string
+		release func(rl *RateLimiter, cancel context.CancelFunc)
+		want    error
+	}{
+		{"served", func(rl *RateLimiter, _ context.CancelFunc) { rl.SetRate(1000) }, nil},
+		{"cancelled", func(_ *RateLimiter, cancel context.CancelFunc) { cancel() }, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter(0.1, 1)
+			defer rl.Stop()
+			rl.Allow()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errs := make(chan error, waiters)
+			for range waiters {
+				go func() { errs <- rl.Wait(ctx) }()
+			}
+			waitFor(t, "the waiters to block", func() bool { return rl.Pending() == waiters })
+
+			tt.release(rl, cancel)
+			for range waiters {
+				select {
+				case err := <-errs:
+					if !errors.Is(err, tt.want) {
+						t.Errorf("Wait = %v, want %v", err, tt.want)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("a waiter was never released")
+				}
+			}
+			if got := rl.Pending(); got != 0 {
+				t.Errorf("Pending = %d once every waiter returned, want 0", got)
+			}
+		})
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	rl := NewRateLimiter(4, 1)
+	defer rl.Stop()
+
+	r := rl.Reserve()
+	if !r.OK() || r.Delay() != 0 {
+		t.Fatalf("Reserve with a full bucket: OK=%v Delay=%v, want OK with no delay", r.OK(), r.Delay())
+	}
+
+	empty := rl.Reserve()
+	if empty.OK() {
+		t.Fatal("Reserve succeeded with an empty bucket")
+	}
+	if d := empty.Delay(); d <= 200*time.Millisecond || d > 250*time.Millisecond {
+		t.Errorf("Delay = %v, want just under the 250ms refill interval", d)
+	}
+	empty.Cancel() // no token to hand back
+
+	r.Cancel()
+	r.Cancel() // a second cancel must not add another token
+	if !rl.Allow() {
+		t.Fatal("Cancel did not hand the token back")
+	}
+	if rl.Allow() {
+		t.Error("cancelling twice handed back two tokens")
+	}
+}
+
+func TestRateLimiterSetRate(t *testing.T) {
+	tests := []struct {
+		from, to float64
+	}{
+		{100, 20},
+		{20, 100},
+	}
+	for _, tt := range tests {
+		rl := NewRateLimiter(tt.from, 1)
+		checkMeasuredRate(t, "before SetRate", measureRate(t, rl, 5), tt.from)
+		rl.SetRate(tt.to)
+		checkMeasuredRate(t, "after SetRate", measureRate(t, rl, 5), tt.to)
+
+		expectPanic(t, "SetRate(0)", func() { rl.SetRate(0) })
+		rl.Stop()
+	}
+}
+
+func TestRateLimiterSetBurst(t *testing.T) {
+	tests := []struct {
+		from, to, want int
+	}{
+		{5, 2, 2},
+		{2, 5, 2},
+		{3, 3, 3},
+	}
+	for _, tt := range tests {
+		rl := NewRateLimiter(0.1, tt.from)
+		rl.SetBurst(tt.to)
+
+		var got int
+		for rl.Allow() {
+			got++
+		}
+		if got != tt.want {
+			t.Errorf("burst %d -> %d: %d tokens available, want %d", tt.from, tt.to, got, tt.want)
+		}
+		expectPanic(t, "SetBurst(0)", func() { rl.SetBurst(0) })
+		rl.Stop()
+	}
+}
+
+func TestRateLimiterWarmup(t *testing.T) {
+	rl := NewRateLimiterWarmup(30, 5, time.Hour)
+	defer rl.Stop()
+
+	if rl.Allow() {
+		t.Error("a warming-up limiter should start with an empty bucket")
+	}
+	if got := rl.effectiveRate(); got < 10 || got > 10.1 {
+		t.Errorf("effectiveRate at start = %v, want about a third of 30", got)
+	}
+
+	warm := NewRateLimiterWarmup(30, 5, time.Nanosecond)
+	defer warm.Stop()
+	time.Sleep(time.Millisecond)
+	if got := warm.effectiveRate(); got != 30 {
+		t.Errorf("effectiveRate after warmup = %v, want 30", got)
+	}
+}
+
+func TestRateLimiterJitter(t *testing.T) {
+	const seed, jitter, waits = 7, 40 * time.Millisecond, 6
+
+	// The delays Wait should draw from the seeded source, in order
+	rng := rand.New(rand.NewSource(seed))
+	var want []time.Duration
+	for range waits {
+		want = append(want, time.Duration(rng.Int63n(int64(jitter))))
+	}
+
+	tests := []struct {
+		name string
+		rl   func() *RateLimiter
+	}{
+		{"options", func() *RateLimiter {
+			return NewRateLimiter(10, waits, WithLimiterJitter(jitter), WithLimiterRandSource(rand.New(rand.NewSource(seed))))
+		}},
+		{"setters", func() *RateLimiter {
+			rl := NewRateLimiter(10, waits)
+			rl.SetJitter(jitter)
+			rl.SetRandSource(rand.New(rand.NewSource(seed)))
+			return rl
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := tt.rl()
+			defer rl.Stop()
+
+			// The bucket holds a token for every Wait, so each one takes
+			// only as long as its jitter
+			shortest, longest := time.Duration(math.MaxInt64), time.Duration(0)
+			for i := range waits {
+				start := time.Now()
+				if err := rl.Wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				elapsed := time.Since(start)
+				if elapsed < want[i] || elapsed > jitter+20*time.Millisecond {
+					t.Errorf("Wait %d took %v, want the seeded jitter of %v and no more than %v", i+1, elapsed, want[i], jitter)
+				}
+				shortest, longest = min(shortest, elapsed), max(longest, elapsed)
+			}
+			if longest-shortest < jitter/4 {
+				t.Errorf("Waits took between %v and %v, want them spread across the %v jitter", shortest, longest, jitter)
+			}
+		})
+	}
+}
+
+func TestMultiLimiter(t *testing.T) {
+	perSecond := NewRateLimiter(0.1, 2)
+	perHour := NewRateLimiter(0.1, 1)
+	defer perSecond.Stop()
+	defer perHour.Stop()
+	ml := NewMultiLimiter(perSecond, perHour)
+
+	if !ml.Allow() {
+		t.Fatal("Allow refused with every limiter full")
+	}
+	if ml.Allow() {
+		t.Fatal("Allow succeeded although one limiter was empty")
+	}
+	if !perSecond.Allow() {
+		t.Error("a refused Allow kept the token it reserved from the other limiter")
+	}
+}
+
+func TestNewMultiLimiterRejectsOtherLimiters(t *testing.T) {
+	rl := NewRateLimiter(0.1, 1)
+	defer rl.Stop()
+	expectPanic(t, "NewMultiLimiter with a limiter that can't Reserve", func() {
+		NewMultiLimiter(rl, newFakeLimiter(1, nil))
+	})
+}
+
+func TestMultiLimiterSlowestSetsRate(t *testing.T) {
+	tests := []struct {
+		name string
+		fast bool // list the fast limiter first
+	}{
+		{"fast first", true},
+		{"slow first", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fast := NewRateLimiter(100, 1)
+			slow := NewRateLimiter(20, 1)
+			defer fast.Stop()
+			defer slow.Stop()
+
+			ml := NewMultiLimiter(slow, fast)
+			if tt.fast {
+				ml = NewMultiLimiter(fast, slow)
+			}
+			checkMeasuredRate(t, "MultiLimiter", measureRate(t, ml, 5), 20)
+		})
+	}
+}
+
+func TestMultiLimiterWait(t *testing.T) {
+	tests := []struct {
+		name string
+		stop bool
+		want error
+	}{
+		{"refills", false, nil},
+		{"member stopped", true, ErrLimiterStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fast := NewRateLimiter(100, 1)
+			other := NewRateLimiter(100, 1)
+			defer fast.Stop()
+			defer other.Stop()
+			fast.Allow()
+			if tt.stop {
+				fast.Stop()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := NewMultiLimiter(fast, other).Wait(ctx)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Wait = %v, want %v", err, tt.want)
+			}
+			if tt.stop && !other.Allow() {
+				t.Error("Wait kept a token from the running limiter after failing")
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter func() Limiter
+		jobs    int
+		want    int
+	}{
+		{"forwards every job", func() Limiter { return NewRateLimiter(1000, 5) }, 5, 5},
+		{"stops when Wait fails", func() Limiter { return newFakeLimiter(0, ErrLimiterStopped) }, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := tt.limiter()
+			if rl, ok := limiter.(*RateLimiter); ok {
+				defer rl.Stop()
+			}
+
+			jobs := make(chan int, tt.jobs)
+			for i := 0; i < tt.jobs; i++ {
+				jobs <- i
+			}
+			close(jobs)
+
+			var got []int
+			for job := range Throttle(limiter, jobs) {
+				got = append(got, job)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("forwarded %v, want %d jobs", got, tt.want)
+			}
+			for i, job := range got {
+				if job != i {
+					t.Errorf("forwarded %v out of order", got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestThrottlePacesJobs(t *testing.T) {
+	const interval, jobs = 20 * time.Millisecond, 6
+	rl := NewRateLimiter(float64(time.Second/interval), 1)
+	defer rl.Stop()
+
+	in := make(chan int, jobs)
+	for i := range jobs {
+		in <- i
+	}
+	close(in)
+
+	// The first job spends the burst; each later one waits for a refill
+	start := time.Now()
+	var last time.Time
+	for range Throttle(rl, in) {
+		now := time.Now()
+		if !last.IsZero() && now.Sub(last) < interval/2 {
+			t.Errorf("jobs forwarded %v apart, want about %v", now.Sub(last), interval)
+		}
+		last = now
+	}
+	if span, want := last.Sub(start), (jobs-1)*interval; span < want*9/10 || span > want*2 {
+		t.Errorf("forwarded %d jobs over %v, want about %v", jobs, span, want)
+	}
+}
+
+func TestKeyedRateLimiterValidates(t *testing.T) {
+	expectPanic(t, "NewKeyedRateLimiter with zero rate", func() {
+		NewKeyedRateLimiter(0, 1, time.Minute)
+	})
+	expectPanic(t, "NewKeyedRateLimiter with zero burst", func() {
+		NewKeyedRateLimiter(1, 0, time.Minute)
+	})
+}
+
+func TestKeyedRateLimiter(t *testing.T) {
+	kl := NewKeyedRateLimiter(0.1, 1, time.Hour)
+	defer kl.Stop()
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"alice", true},
+		{"alice", false},
+		{"bob", true},
+		{"bob", false},
+	}
+	for _, tt := range tests {
+		if got := kl.Allow(tt.key); got != tt.want {
+			t.Errorf("Allow(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+	if kl.Len() != 2 {
+		t.Errorf("Len = %d, want 2", kl.Len())
+	}
+	if removed := kl.Cleanup(); removed != 0 {
+		t.Errorf("Cleanup removed %d recently used keys", removed)
+	}
+}
+
+func TestKeyedRateLimiterCleanupAndStop(t *testing.T) {
+	kl := NewKeyedRateLimiter(0.1, 1, time.Millisecond)
+	kl.Allow("alice")
+	kl.Allow("bob")
+	time.Sleep(5 * time.Millisecond)
+
+	if removed := kl.Cleanup(); removed != 2 {
+		t.Errorf("Cleanup removed %d idle keys, want 2", removed)
+	}
+	if !kl.Allow("alice") {
+		t.Error("a dropped key should start again with a full bucket")
+	}
+
+	kl.Stop()
+	kl.Stop()
+	if kl.Len() != 0 {
+		t.Errorf("Len after Stop = %d, want 0", kl.Len())
+	}
+	if kl.Allow("carol") {
+		t.Error("Allow succeeded after Stop")
+	}
+}
+
+func TestKeyedRateLimiterRefillsLazily(t *testing.T) {
+	kl := NewKeyedRateLimiter(100, 2, time.Hour)
+	defer kl.Stop()
+
+	for kl.Allow("alice") {
+	}
+	time.Sleep(15 * time.Millisecond)
+	if !kl.Allow("alice") {
+		t.Error("no token earned after 1.5 refill intervals")
+	}
+
+	// Idle time earns no more than the burst
+	time.Sleep(50 * time.Millisecond)
+	var got int
+	for kl.Allow("alice") {
+		got++
+	}
+	if got != 2 {
+		t.Errorf("%d tokens after a long idle spell, want the burst of 2", got)
+	}
+}
+
+func TestKeyedRateLimiterStartsNoGoroutines(t *testing.T) {
+	kl := NewKeyedRateLimiter(10, 1, time.Hour)
+	defer kl.Stop()
+
+	before := runtime.NumGoroutine()
+	for i := range 1000 {
+		kl.Allow(strconv.Itoa(i))
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines grew from %d to %d tracking 1000 keys", before, after)
+	}
+}
+
+func TestKeyedRateLimiterAllowDuringCleanup(t *testing.T) {
+	const workers, calls = 8, 100
+
+	// Every key is idle at once, so Cleanup drops whatever it finds; the
+	// burst covers all the calls, so Allow must never refuse
+	kl := NewKeyedRateLimiter(1, workers*calls, 0)
+	defer kl.Stop()
+
+	stop := make(chan struct{})
+	cleaned := make(chan struct{})
+	go func() {
+		defer close(cleaned)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				kl.Cleanup()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var refused atomic.Int64
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range calls {
+				if !kl.Allow("shared") {
+					refused.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-cleaned
+
+	if n := refused.Load(); n > 0 {
+		t.Errorf("Allow refused %d calls while Cleanup ran", n)
+	}
+}
+
+func TestLeakyBucket(t *testing.T) {
+	expectPanic(t, "NewLeakyBucket with zero rate", func() {
+		NewLeakyBucket(0, 1).Stop()
+	})
+
+	lb := NewLeakyBucket(0.1, 2)
+	defer lb.Stop()
+
+	tests := []bool{true, true, false}
+	for i, want := range tests {
+		if got := lb.Submit(); got != want {
+			t.Errorf("Submit %d = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestLeakyBucketDrainsEvenly(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	lb := NewLeakyBucket(float64(time.Second/interval), 3)
+	defer lb.Stop()
+	for i := 0; i < 3; i++ {
+		lb.Submit()
+	}
+
+	var last time.Time
+	for i := 0; i < 3; i++ {
+		select {
+		case at := <-lb.Drained():
+			if !last.IsZero() && at.Sub(last) < interval/2 {
+				t.Errorf("requests released %v apart, want about %v", at.Sub(last), interval)
+			}
+			last = at
+		case <-time.After(time.Second):
+			t.Fatalf("request %d was never released", i+1)
+		}
+	}
+}
+
+func TestSendRateLimited(t *testing.T) {
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		limiter     *fakeLimiter
+		burstServed int
+		rateLimited int
+	}{
+		{"burst then wait", context.Background(), newFakeLimiter(2, nil), 2, 2},
+		{"wait fails", context.Background(), newFakeLimiter(1, ErrLimiterStopped), 1, 0},
+		{"cancelled", cancelledCtx, newFakeLimiter(4, nil), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := sendRateLimited(tt.ctx, tt.limiter, apiRequests(4))
+			if stats.BurstServed != tt.burstServed || stats.RateLimited != tt.rateLimited {
+				t.Errorf("got %d burst, %d rate limited; want %d and %d",
+					stats.BurstServed, stats.RateLimited, tt.burstServed, tt.rateLimited)
+			}
+		})
+	}
+}
+
+func TestRunRateLimiterConcurrentStats(t *testing.T) {
+	quietOutput(t)
+
+	// One token up front, then one every 100ms while each request takes
+	// about 50ms, so every later request waits about 50ms
+	stats := runRateLimiterConcurrent(context.Background(), 10, 1, apiRequests(5))
+	if stats.BurstServed != 1 || stats.RateLimited != 4 {
+		t.Errorf("got %d burst, %d rate limited; want 1 and 4", stats.BurstServed, stats.RateLimited)
+	}
+	if stats.TotalWait < 100*time.Millisecond || stats.TotalWait > time.Second {
+		t.Errorf("TotalWait = %v, want about 200ms", stats.TotalWait)
+	}
+}
+
+func TestRunRateLimiterConcurrentStopsLimiter(t *testing.T) {
+	quietOutput(t)
+	before := runtime.NumGoroutine()
+
+	runRateLimiterConcurrent(context.Background(), 100, 2, apiRequests(4))
+	waitFor(t, "the limiter's goroutine to exit", func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestAPIRequestsRepeatSamples(t *testing.T) {
+	requests := apiRequests(len(sampleAPIRequests) + 2)
+	if len(requests) != len(sampleAPIRequests)+2 {
+		t.Fatalf("got %d requests", len(requests))
+	}
+	if requests[len(sampleAPIRequests)] != sampleAPIRequests[0] {
+		t.Errorf("requests past the samples should start over")
+	}
+}
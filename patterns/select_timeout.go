@@ -1,113 +1,498 @@
 package patterns
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"math/rand"
+	"slices"
+	"sync"
 	"time"
 )
 
-func SelectTimeout() {
-	fmt.Println("=== Select Statement with Timeout Pattern ===")
-	fmt.Println("Non-blocking channel operations with timeouts and graceful error handling")
-	fmt.Println("Use case: Service health checks with timeouts to prevent hanging")
-	fmt.Println()
+// SelectTimeout asks for a timeout and runs the health check comparison,
+// which stops early if ctx is cancelled.
+func SelectTimeout(ctx context.Context) {
+	selectTimeoutHeader()
+	timeout := promptDuration("Health check timeout", 500*time.Millisecond)
+	console.Println()
 
+	runSelectTimeoutComparison(ctx, timeout)
+}
+
+// SelectTimeoutComparison runs the health check comparison with a 500ms
+// timeout, without asking for input. Cancelling ctx cuts it short.
+func SelectTimeoutComparison(ctx context.Context) RunSummary {
+	selectTimeoutHeader()
+	return runSelectTimeoutComparison(ctx, 500*time.Millisecond)
+}
+
+func selectTimeoutHeader() {
+	console.Println("=== Select Statement with Timeout Pattern ===")
+	console.Println("Non-blocking channel operations with timeouts and graceful error handling")
+	console.Println("Use case: Service health checks with timeouts to prevent hanging")
+	console.Println()
+}
+
+func runSelectTimeoutComparison(ctx context.Context, timeout time.Duration) RunSummary {
 	// Run concurrent version
-	fmt.Println("Running CONCURRENT (with timeouts) version...")
+	console.Detailln("Running CONCURRENT (with timeouts) version...")
 	concurrentStart := time.Now()
-	runSelectTimeoutConcurrent()
+	report := runSelectTimeoutConcurrent(ctx, timeout)
 	concurrentDuration := time.Since(concurrentStart)
 
-	fmt.Printf("\nCONCURRENT (with timeouts) version took: %v\n\n", concurrentDuration)
+	summary := RunSummary{
+		Pattern:    "select-timeout",
+		Completed:  len(report.Services) - report.TimedOut - report.Cancelled,
+		Concurrent: concurrentDuration,
+		Details: map[string]any{
+			"healthy":  report.Healthy,
+			"degraded": report.Degraded,
+			"failed":   report.Failed,
+			"timedOut": report.TimedOut,
+			"verdict":  report.Verdict(0.8),
+		},
+	}
+	if cancelled(ctx, &summary) {
+		return summary
+	}
+
+	console.Printf("\nCONCURRENT (with timeouts) version took: %v\n\n", concurrentDuration)
 
 	// Run sequential version for comparison
-	fmt.Println("Running SEQUENTIAL (blocking) version for comparison...")
+	console.Detailln("Running SEQUENTIAL (blocking) version for comparison...")
 	sequentialStart := time.Now()
-	runSelectTimeoutSequential()
-	sequentialDuration := time.Since(sequentialStart)
+	runSelectTimeoutSequential(ctx)
+	summary.Sequential = time.Since(sequentialStart)
+	if cancelled(ctx, &summary) {
+		return summary
+	}
 
-	fmt.Printf("\nSEQUENTIAL (blocking) version took: %v\n", sequentialDuration)
-	fmt.Printf("Concurrent version handles failures gracefully with timeouts!\n\n")
+	console.Printf("\nSEQUENTIAL (blocking) version took: %v\n", summary.Sequential)
+	console.Printf("Concurrent version handles failures gracefully with timeouts!\n\n")
+	return summary
 }
 
-func runSelectTimeoutConcurrent() {
-	
-	services := []string{
-		"Database Service",
-		"Cache Service", 
-		"Auth Service",
-		"Payment Service",
-		"Notification Service",
+func runSelectTimeoutConcurrent(ctx context.Context, timeout time.Duration) HealthReport {
+	// Answers in the last fifth of the timeout count as degraded
+	report := CheckServicesContext(ctx, demoServices, timeout, WithWarnAfter(timeout*4/5))
+	for _, service := range report.Services {
+		console.Detailf("  %-22s %-12v %v\n", service.Name, service.Status, service.ResponseTime.Round(time.Millisecond))
 	}
+	console.Printf("Health Check Results - Healthy: %d, Degraded: %d, Failed: %d, Timeouts: %d\n", report.Healthy, report.Degraded, report.Failed, report.TimedOut)
+	if latency := report.Latency; latency.Samples > 0 {
+		console.Printf("Latency (%d answered) - min: %v, mean: %v, p95: %v, max: %v\n",
+			latency.Samples, latency.Min.Round(time.Millisecond), latency.Mean.Round(time.Millisecond),
+			latency.P95.Round(time.Millisecond), latency.Max.Round(time.Millisecond))
+	}
+	console.Printf("Overall verdict (80%% healthy required): %s\n", report.Verdict(0.8))
+	return report
+}
 
-	var healthyServices, timeoutServices, failedServices int
+// demoServices are the services checked by the select-timeout demo.
+var demoServices = []string{
+	"Database Service",
+	"Cache Service",
+	"Auth Service",
+	"Payment Service",
+	"Notification Service",
+}
+
+// HealthStatus is the outcome of checking a single service.
+type HealthStatus int
+
+const (
+	Healthy HealthStatus = iota
+	Degraded
+	Failed
+	TimedOut
+	Cancelled
+)
 
+func (hs HealthStatus) String() string {
+	switch hs {
+	case Healthy:
+		return label("✅ HEALTHY", "[HEALTHY]")
+	case Degraded:
+		return label("🐢 DEGRADED", "[DEGRADED]")
+	case Failed:
+		return label("❌ FAILED", "[FAILED]")
+	case TimedOut:
+		return label("⏰ TIMED_OUT", "[TIMED_OUT]")
+	case Cancelled:
+		return label("🚫 CANCELLED", "[CANCELLED]")
+	default:
+		return label("❓ UNKNOWN", "[UNKNOWN]")
+	}
+}
+
+// ServiceHealth is the result of checking one service. ResponseTime is how
+// long the last attempt took as seen by the checker; for a timed-out service
+// it is the time spent waiting before giving up. Attempts counts the checks
+// made, including retries.
+type ServiceHealth struct {
+	Name         string
+	Status       HealthStatus
+	ResponseTime time.Duration
+	Attempts     int
+	Err          error
+}
+
+// HealthReport holds the per-service results of a health check sweep, in
+// the order the services were given, along with the count for each outcome
+// and a summary of the response times.
+type HealthReport struct {
+	Services  []ServiceHealth
+	Healthy   int
+	Degraded  int
+	Failed    int
+	TimedOut  int
+	Cancelled int
+	Latency   LatencyStats
+}
+
+// Verdict sums the report up in one word for callers that only need to know
+// whether the system is healthy enough. threshold is the fraction (0-1) of
+// the services that must be Healthy:
+//
+//   - "HEALTHY" when at least threshold of them are Healthy.
+//   - "DEGRADED" when that is not met, but at least one service is Healthy
+//     and at least half of threshold are Healthy or Degraded.
+//   - "CRITICAL" otherwise. With no Healthy service at all the system is
+//     CRITICAL however many are Degraded, since nothing answers at its
+//     normal speed.
+//
+// A report with no services is "HEALTHY".
+func (r HealthReport) Verdict(threshold float64) string {
+	total := float64(len(r.Services))
+	if total == 0 {
+		return "HEALTHY"
+	}
+
+	healthy := float64(r.Healthy) / total
+	up := float64(r.Healthy+r.Degraded) / total
+	switch {
+	case healthy >= threshold:
+		return "HEALTHY"
+	case r.Healthy > 0 && up >= threshold/2:
+		return "DEGRADED"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// LatencyStats summarizes the response times of the checks that got an
+// answer, whether healthy, degraded or failed. Checks that timed out or were
+// cancelled have no real response time, so they are left out of the figures
+// and only counted in Excluded.
+type LatencyStats struct {
+	Samples  int
+	Excluded int
+	Min      time.Duration
+	Max      time.Duration
+	Mean     time.Duration
+	P95      time.Duration
+}
+
+// latencyStats computes LatencyStats over services. P95 uses the
+// nearest-rank method: the smallest sample that at least 95% of the samples
+// do not exceed.
+func latencyStats(services []ServiceHealth) LatencyStats {
+	var stats LatencyStats
+	var samples []time.Duration
 	for _, service := range services {
-		// Create channels for different outcomes
-		resultCh := make(chan string, 1)
-		errorCh := make(chan error, 1)
-
-		// Start health check in goroutine
-		go func(svc string) {
-			// Simulate variable response times and failures
-			responseTime := time.Duration(rand.Intn(800)+100) * time.Millisecond
-			
-			// 20% chance of service being down
-			if rand.Float32() < 0.2 {
-				time.Sleep(responseTime)
-				errorCh <- fmt.Errorf("%s is down", svc)
+		if service.Status == TimedOut || service.Status == Cancelled {
+			stats.Excluded++
+			continue
+		}
+		samples = append(samples, service.ResponseTime)
+	}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	slices.Sort(samples)
+	var total time.Duration
+	for _, sample := range samples {
+		total += sample
+	}
+
+	stats.Samples = len(samples)
+	stats.Min = samples[0]
+	stats.Max = samples[len(samples)-1]
+	stats.Mean = total / time.Duration(len(samples))
+	stats.P95 = samples[(len(samples)*95+99)/100-1]
+	return stats
+}
+
+func (r *HealthReport) add(service ServiceHealth) {
+	r.Services = append(r.Services, service)
+	switch service.Status {
+	case Healthy:
+		r.Healthy++
+	case Degraded:
+		r.Degraded++
+	case Failed:
+		r.Failed++
+	case TimedOut:
+		r.TimedOut++
+	case Cancelled:
+		r.Cancelled++
+	}
+}
+
+// CheckServices checks all services at once, giving each up to timeout to
+// answer before counting it as timed out; WithServiceTimeouts overrides the
+// timeout for individual services. Results are collected as they arrive, so
+// the sweep takes about as long as the slowest check rather than the sum of
+// them.
+func CheckServices(services []string, timeout time.Duration, opts ...HealthOption) HealthReport {
+	return CheckServicesContext(context.Background(), services, timeout, opts...)
+}
+
+// CheckServicesContext is CheckServices with cancellation. Once ctx is done
+// no further checks or retries are started, checks in flight are abandoned
+// and the report is returned straight away, with every service that had not
+// finished marked Cancelled.
+func CheckServicesContext(ctx context.Context, services []string, timeout time.Duration, opts ...HealthOption) HealthReport {
+	cfg := newHealthConfig(opts)
+
+	checked := make([]ServiceHealth, len(services))
+	for i, service := range services {
+		checked[i] = ServiceHealth{Name: service, Status: Cancelled}
+	}
+
+	// Buffered so abandoned checks can still deliver and exit
+	results := make(chan Indexed[ServiceHealth], len(services))
+	launched := 0
+	for i, service := range services {
+		if ctx.Err() != nil {
+			break
+		}
+		launched++
+		go func() {
+			results <- Indexed[ServiceHealth]{Index: i, Value: checkService(ctx, service, cfg.timeoutFor(service, timeout), cfg)}
+		}()
+	}
+
+collect:
+	for range launched {
+		select {
+		case result := <-results:
+			checked[result.Index] = result.Value
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	var report HealthReport
+	for _, service := range checked {
+		if service.Status == Cancelled {
+			service.Err = ctx.Err()
+		}
+		report.add(service)
+	}
+	report.Latency = latencyStats(report.Services)
+	return report
+}
+
+// MonitorServices runs a health check sweep straight away and then once every
+// interval, sending each report on the returned channel. A sweep that is
+// still running, or a report nobody has read yet, delays the next sweep
+// rather than piling up behind it. Once ctx is done the monitor stops,
+// discarding any sweep cut short, and closes the channel. It panics if
+// interval is not positive.
+func MonitorServices(ctx context.Context, services []string, interval, timeout time.Duration, opts ...HealthOption) <-chan HealthReport {
+	if interval <= 0 {
+		panic(fmt.Sprintf("patterns: MonitorServices needs a positive interval, got %v", interval))
+	}
+
+	reports := make(chan HealthReport)
+
+	go func() {
+		defer close(reports)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			report := CheckServicesContext(ctx, services, timeout, opts...)
+			if ctx.Err() != nil {
+				return
+			}
+
+			select {
+			case reports <- report:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
 				return
 			}
+		}
+	}()
+
+	return reports
+}
 
-			time.Sleep(responseTime)
-			resultCh <- fmt.Sprintf("%s is healthy (response time: %v)", svc, responseTime)
-		}(service)
+// checkService checks service, retrying a failed or timed-out check as
+// configured. Each attempt gets a fresh timeout, and the wait before each
+// retry doubles, starting from the configured backoff.
+func checkService(ctx context.Context, service string, timeout time.Duration, cfg healthConfig) ServiceHealth {
+	backoff := cfg.backoff
+	for attempt := 1; ; attempt++ {
+		result := checkOnce(ctx, service, timeout, cfg.probe)
+		result.Attempts = attempt
+		if result.Status == Healthy && cfg.warnAfter > 0 && result.ResponseTime > cfg.warnAfter {
+			result.Status = Degraded
+		}
+		if result.Status == Healthy || result.Status == Degraded || result.Status == Cancelled || attempt > cfg.retries {
+			return result
+		}
 
-		// Use select with timeout
 		select {
-		case <-resultCh:
-			healthyServices++
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return result
+		}
+		backoff *= 2
+	}
+}
 
-		case <-errorCh:
-			failedServices++
+// checkOnce runs one health check, giving up once timeout has passed or ctx
+// is done.
+func checkOnce(ctx context.Context, service string, timeout time.Duration, probe HealthProbe) ServiceHealth {
+	// Cancelled as soon as the select below has its answer, so a check that
+	// timed out stops waiting on its service instead of lingering
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		case <-time.After(500 * time.Millisecond):
-			timeoutServices++
+	// Create channels for different outcomes
+	resultCh := make(chan string, 1)
+	errorCh := make(chan error, 1)
 
-		// Demonstrate non-blocking select with default case
-		default:
-			// Wait again with timeout after showing checking message
-			select {
-			case <-resultCh:
-				healthyServices++
+	// Start health check in goroutine
+	go func(svc string) {
+		responseTime, err := probe(svc)
+		select {
+		case <-time.After(responseTime):
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			errorCh <- err
+			return
+		}
+		resultCh <- fmt.Sprintf("%s is healthy (response time: %v)", svc, responseTime)
+	}(service)
 
-			case <-errorCh:
-				failedServices++
+	start := time.Now()
+	result := ServiceHealth{Name: service}
 
-			case <-time.After(500 * time.Millisecond):
-				timeoutServices++
-			}
+	// Wait for whichever comes first: an answer, an error or the timeout
+	select {
+	case <-resultCh:
+		result.Status = Healthy
+
+	case err := <-errorCh:
+		result.Status, result.Err = Failed, err
+
+	case <-time.After(timeout):
+		result.Status = TimedOut
+
+	case <-ctx.Done():
+		result.Status, result.Err = Cancelled, ctx.Err()
+	}
+
+	result.ResponseTime = time.Since(start)
+	return result
+}
+
+// HealthProbe decides how a simulated service responds to a health check:
+// how long it takes to answer and, if it is down, the error it answers with.
+type HealthProbe func(service string) (time.Duration, error)
+
+// randomHealthProbe answers in 100-900ms and is down 20% of the time, drawing
+// from rng. The probe may be called from several goroutines at once, so
+// access to rng is serialized.
+func randomHealthProbe(rng *rand.Rand) HealthProbe {
+	var mu sync.Mutex
+	return func(service string) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Simulate variable response times and failures
+		responseTime := time.Duration(rng.Intn(800)+100) * time.Millisecond
+
+		// 20% chance of service being down
+		if rng.Float32() < 0.2 {
+			return responseTime, fmt.Errorf("%s is down", service)
 		}
+		return responseTime, nil
+	}
+}
+
+// HealthOption configures a health check sweep run by CheckServices.
+type HealthOption func(*healthConfig)
+
+type healthConfig struct {
+	probe     HealthProbe
+	retries   int
+	backoff   time.Duration
+	timeouts  map[string]time.Duration
+	warnAfter time.Duration
+}
+
+func newHealthConfig(opts []HealthOption) healthConfig {
+	cfg := healthConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
 	}
+	if cfg.probe == nil {
+		cfg.probe = randomHealthProbe(rand.New(rand.NewSource(time.Now().UnixNano())))
+	}
+	return cfg
+}
 
-	fmt.Printf("Health Check Results - Healthy: %d, Failed: %d, Timeouts: %d\n", healthyServices, failedServices, timeoutServices)
+// timeoutFor returns the timeout configured for service, or def if it has
+// none.
+func (cfg healthConfig) timeoutFor(service string, def time.Duration) time.Duration {
+	if timeout, ok := cfg.timeouts[service]; ok {
+		return timeout
+	}
+	return def
 }
 
-func runSelectTimeoutSequential() {
-	services := []string{
-		"Database Service",
-		"Cache Service", 
-		"Auth Service",
-		"Payment Service",
-		"Notification Service",
+// WithHealthProbe replaces the simulated services with probe, letting callers
+// decide exactly how each service responds.
+func WithHealthProbe(probe HealthProbe) HealthOption {
+	return func(cfg *healthConfig) {
+		cfg.probe = probe
 	}
+}
 
+// WithHealthRandSource keeps the default simulated services but draws their
+// response times and failures from rng, so a fixed seed gives the same draws
+// every run. The checks run concurrently, so which service gets which draw
+// still depends on scheduling; use WithHealthProbe to pin outcomes to
+// services. The default is a source seeded from the clock.
+func WithHealthRandSource(rng *rand.Rand) HealthOption {
+	return func(cfg *healthConfig) {
+		cfg.probe = randomHealthProbe(rng)
+	}
+}
+
+func runSelectTimeoutSequential(ctx context.Context) {
 	var healthyServices, failedServices int
 
-	for i, service := range services {
+	for i, service := range demoServices {
 		// Simulate variable response times and failures - blocking call
 		responseTime := time.Duration(rand.Intn(800)+100) * time.Millisecond
-		time.Sleep(responseTime)
+		if sleepContext(ctx, responseTime) != nil {
+			return
+		}
 
 		// 20% chance of service being down
 		if rand.Float32() < 0.2 {
@@ -119,12 +504,45 @@ func runSelectTimeoutSequential() {
 		// If a service hangs, this would block forever!
 		// Simulate one hanging service
 		if i == 2 && rand.Float32() < 0.3 {
-			time.Sleep(2 * time.Second)
+			if sleepContext(ctx, 2*time.Second) != nil {
+				return
+			}
 		}
 		
 		_ = service // Use the service variable
 	}
 
-	fmt.Printf("Sequential Results - Healthy: %d, Failed: %d\n", healthyServices, failedServices)
-	fmt.Println("⚠️  Note: Sequential approach vulnerable to hanging services!")
-}
\ No newline at end of file
+	console.Printf("Sequential Results - Healthy: %d, Failed: %d\n", healthyServices, failedServices)
+	console.Println("⚠️  Note: Sequential approach vulnerable to hanging services!")
+}
+
+// WithHealthRetries retries a failed or timed-out check up to retries times
+// before reporting it, waiting backoff before the first retry and twice as
+// long before each one after. This trades a slower sweep for fewer false
+// alarms from a service that blipped once. The default is no retries.
+func WithHealthRetries(retries int, backoff time.Duration) HealthOption {
+	return func(cfg *healthConfig) {
+		cfg.retries = retries
+		cfg.backoff = backoff
+	}
+}
+
+// WithServiceTimeouts gives the named services their own timeout, for
+// services whose expected latency differs from the rest: a payment gateway
+// may deserve seconds where a cache should answer in milliseconds. Services
+// not in timeouts use the sweep's default.
+func WithServiceTimeouts(timeouts map[string]time.Duration) HealthOption {
+	return func(cfg *healthConfig) {
+		cfg.timeouts = maps.Clone(timeouts)
+	}
+}
+
+// WithWarnAfter reports a service that answers successfully but takes longer
+// than warnAfter as Degraded rather than Healthy: it is up, but close enough
+// to its timeout to be worth a look. warnAfter should sit below the timeout;
+// zero, the default, disables the check.
+func WithWarnAfter(warnAfter time.Duration) HealthOption {
+	return func(cfg *healthConfig) {
+		cfg.warnAfter = warnAfter
+	}
+}
@@ -0,0 +1,375 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("down")
+
+// probeAnswer is how a service answers fakeProbe.
+type probeAnswer struct {
+	delay time.Duration
+	err   error
+}
+
+// fakeProbe answers each service as given; unknown services answer at once.
+func fakeProbe(answers map[string]probeAnswer) HealthProbe {
+	return func(service string) (time.Duration, error) {
+		answer := answers[service]
+		return answer.delay, answer.err
+	}
+}
+
+// statuses returns the status of each service in report, in report order.
+func statuses(report HealthReport) []HealthStatus {
+	var all []HealthStatus
+	for _, service := range report.Services {
+		all = append(all, service.Status)
+	}
+	return all
+}
+
+func TestHealthStatusString(t *testing.T) {
+	setPlainOutput(t, true)
+	tests := []struct {
+		status HealthStatus
+		want   string
+	}{
+		{Healthy, "[HEALTHY]"},
+		{Degraded, "[DEGRADED]"},
+		{Failed, "[FAILED]"},
+		{TimedOut, "[TIMED_OUT]"},
+		{Cancelled, "[CANCELLED]"},
+		{HealthStatus(99), "[UNKNOWN]"},
+	}
+	for _, tt := range tests {
+		if got := tt.status.String(); got != tt.want {
+			t.Errorf("HealthStatus(%d) = %q, want %q", int(tt.status), got, tt.want)
+		}
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		name                      string
+		healthy, degraded, failed int
+		threshold                 float64
+		want                      string
+	}{
+		{"no services", 0, 0, 0, 0.8, "HEALTHY"},
+		{"all healthy", 5, 0, 0, 0.8, "HEALTHY"},
+		{"exactly 80% healthy", 4, 0, 1, 0.8, "HEALTHY"},
+		{"exactly 7 of 10 healthy", 7, 0, 3, 0.7, "HEALTHY"},
+		{"just under the threshold", 6, 0, 4, 0.7, "DEGRADED"},
+		{"mixed", 3, 1, 1, 0.8, "DEGRADED"},
+		{"exactly half the threshold up", 1, 1, 3, 0.8, "DEGRADED"},
+		{"under half the threshold up", 1, 0, 3, 0.8, "CRITICAL"},
+		{"only degraded", 0, 5, 0, 0.8, "CRITICAL"},
+		{"all down", 0, 0, 5, 0.8, "CRITICAL"},
+	}
+	for _, tt := range tests {
+		report := HealthReport{Healthy: tt.healthy, Degraded: tt.degraded, Failed: tt.failed}
+		report.Services = make([]ServiceHealth, tt.healthy+tt.degraded+tt.failed)
+		if got := report.Verdict(tt.threshold); got != tt.want {
+			t.Errorf("%s: Verdict(%v) = %s, want %s", tt.name, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		services []ServiceHealth
+		want     LatencyStats
+	}{
+		{"none", nil, LatencyStats{}},
+		{
+			name: "timeouts and cancellations excluded",
+			services: []ServiceHealth{
+				{Status: Healthy, ResponseTime: 10 * ms},
+				{Status: TimedOut, ResponseTime: 500 * ms},
+				{Status: Failed, ResponseTime: 30 * ms},
+				{Status: Cancelled, ResponseTime: 900 * ms},
+				{Status: Degraded, ResponseTime: 20 * ms},
+			},
+			want: LatencyStats{Samples: 3, Excluded: 2, Min: 10 * ms, Max: 30 * ms, Mean: 20 * ms, P95: 30 * ms},
+		},
+		{
+			name:     "only timeouts",
+			services: []ServiceHealth{{Status: TimedOut}, {Status: TimedOut}},
+			want:     LatencyStats{Excluded: 2},
+		},
+	}
+	for _, tt := range tests {
+		if got := latencyStats(tt.services); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyStatsP95(t *testing.T) {
+	tests := []struct {
+		samples int
+		want    time.Duration
+	}{
+		{1, 1},
+		{19, 19},
+		{20, 19},
+		{100, 95},
+	}
+	for _, tt := range tests {
+		var services []ServiceHealth
+		// Added in reverse so latencyStats has to sort them
+		for i := tt.samples; i > 0; i-- {
+			services = append(services, ServiceHealth{Status: Healthy, ResponseTime: time.Duration(i)})
+		}
+		if got := latencyStats(services).P95; got != tt.want {
+			t.Errorf("%d samples: P95 = %v, want %v", tt.samples, got, tt.want)
+		}
+	}
+}
+
+// checkReport runs CheckServices over services answered by probe and checks
+// the report against the statuses wanted, in service order.
+func checkReport(t *testing.T, services []string, probe HealthProbe, opts []HealthOption, want []HealthStatus) HealthReport {
+	t.Helper()
+	report := CheckServices(services, 200*time.Millisecond, append(opts, WithHealthProbe(probe))...)
+
+	var names []string
+	for _, service := range report.Services {
+		names = append(names, service.Name)
+	}
+	if !slices.Equal(names, services) {
+		t.Errorf("report order %v, want %v", names, services)
+	}
+	if got := statuses(report); !slices.Equal(got, want) {
+		t.Errorf("statuses %v, want %v", got, want)
+	}
+
+	var counts [Cancelled + 1]int
+	for _, status := range want {
+		counts[status]++
+	}
+	got := [...]int{report.Healthy, report.Degraded, report.Failed, report.TimedOut, report.Cancelled}
+	if got != counts {
+		t.Errorf("counts %v, want %v", got, counts)
+	}
+	return report
+}
+
+func TestCheckServices(t *testing.T) {
+	probe := fakeProbe(map[string]probeAnswer{
+		"fast": {delay: time.Millisecond},
+		"down": {delay: time.Millisecond, err: errDown},
+		"hung": {delay: time.Hour},
+	})
+	report := checkReport(t, []string{"hung", "fast", "down"}, probe, nil, []HealthStatus{TimedOut, Healthy, Failed})
+	if !errors.Is(report.Services[2].Err, errDown) {
+		t.Errorf("failed service error = %v, want %v", report.Services[2].Err, errDown)
+	}
+	if report.Latency.Excluded != report.TimedOut {
+		t.Errorf("latency excluded %d, want the %d timeouts", report.Latency.Excluded, report.TimedOut)
+	}
+}
+
+func TestCheckServicesConcurrently(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	probe := fakeProbe(map[string]probeAnswer{"hung": {delay: time.Hour}})
+
+	start := time.Now()
+	report := CheckServices([]string{"hung", "hung", "hung", "hung", "hung"}, timeout, WithHealthProbe(probe))
+	if elapsed := time.Since(start); elapsed > 3*timeout {
+		t.Errorf("5 hung services took %v with a %v timeout, want about one timeout", elapsed, timeout)
+	}
+	if report.TimedOut != 5 {
+		t.Errorf("got %+v, want 5 timeouts", report)
+	}
+}
+
+func TestCheckServicesSingleTimeoutWindow(t *testing.T) {
+	const timeout = 40 * time.Millisecond
+
+	// Answers after the timeout but well within two of them
+	probe := fakeProbe(map[string]probeAnswer{"slow": {delay: timeout * 3 / 2}})
+	start := time.Now()
+	report := CheckServices([]string{"slow"}, timeout, WithHealthProbe(probe))
+	if got := report.Services[0].Status; got != TimedOut {
+		t.Errorf("status %v, want TimedOut", got)
+	}
+	if elapsed := time.Since(start); elapsed >= timeout*3/2 {
+		t.Errorf("check took %v, want it to give up after the %v timeout", elapsed, timeout)
+	}
+}
+
+func TestCheckServicesPerServiceTimeouts(t *testing.T) {
+	probe := fakeProbe(map[string]probeAnswer{
+		"slow":  {delay: 60 * time.Millisecond},
+		"slow2": {delay: 60 * time.Millisecond},
+	})
+	opts := []HealthOption{WithServiceTimeouts(map[string]time.Duration{"slow": 20 * time.Millisecond})}
+	checkReport(t, []string{"slow", "slow2"}, probe, opts, []HealthStatus{TimedOut, Healthy})
+}
+
+func TestCheckServicesDegraded(t *testing.T) {
+	probe := fakeProbe(map[string]probeAnswer{
+		"fast": {delay: time.Millisecond},
+		"slow": {delay: 60 * time.Millisecond},
+	})
+	opts := []HealthOption{WithWarnAfter(30 * time.Millisecond)}
+	checkReport(t, []string{"fast", "slow"}, probe, opts, []HealthStatus{Healthy, Degraded})
+}
+
+func TestCheckServicesTimeout(t *testing.T) {
+	probe := fakeProbe(map[string]probeAnswer{"api": {delay: 50 * time.Millisecond}})
+	tests := []struct {
+		timeout time.Duration
+		want    HealthStatus
+	}{
+		{10 * time.Millisecond, TimedOut},
+		{time.Second, Healthy},
+	}
+	for _, tt := range tests {
+		report := CheckServices([]string{"api"}, tt.timeout, WithHealthProbe(probe))
+		if got := report.Services[0].Status; got != tt.want {
+			t.Errorf("timeout %v: status %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestCheckServicesRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		want         HealthStatus
+		wantAttempts int
+	}{
+		{"no retries", 0, Failed, 1},
+		{"too few retries", 1, Failed, 2},
+		{"recovers on the last retry", 2, Healthy, 3},
+		{"stops retrying once healthy", 5, Healthy, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fails its first two checks
+			var mu sync.Mutex
+			calls := 0
+			probe := func(string) (time.Duration, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if calls <= 2 {
+					return 0, errDown
+				}
+				return 0, nil
+			}
+
+			report := CheckServices([]string{"flaky"}, time.Second, WithHealthProbe(probe), WithHealthRetries(tt.retries, time.Millisecond))
+			got := report.Services[0]
+			if got.Status != tt.want || got.Attempts != tt.wantAttempts {
+				t.Errorf("got %v after %d attempts, want %v after %d", got.Status, got.Attempts, tt.want, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestCheckServicesContextCancel(t *testing.T) {
+	probe := fakeProbe(map[string]probeAnswer{"hung": {delay: time.Hour}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	report := CheckServicesContext(ctx, []string{"fast", "hung"}, time.Hour, WithHealthProbe(probe))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sweep took %v after its context expired", elapsed)
+	}
+	if got := statuses(report); !slices.Equal(got, []HealthStatus{Healthy, Cancelled}) {
+		t.Errorf("statuses %v, want [Healthy Cancelled]", got)
+	}
+	if err := report.Services[1].Err; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cancelled service error = %v, want the context's error", err)
+	}
+
+	// Nothing is started once ctx is already done
+	report = CheckServicesContext(ctx, []string{"fast"}, time.Hour, WithHealthProbe(probe))
+	if report.Cancelled != 1 {
+		t.Errorf("a sweep with a done context reported %+v", report)
+	}
+}
+
+func TestCheckServicesTimeoutDoesNotLeak(t *testing.T) {
+	probe := fakeProbe(map[string]probeAnswer{"hung": {delay: time.Hour}})
+	before := runtime.NumGoroutine()
+
+	report := CheckServices([]string{"hung", "hung", "hung"}, 10*time.Millisecond, WithHealthProbe(probe))
+	if report.TimedOut != 3 {
+		t.Fatalf("got %+v, want 3 timeouts", report)
+	}
+	waitFor(t, "timed-out checks to exit", func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestRandomHealthProbeSeeded(t *testing.T) {
+	draws := func() []probeAnswer {
+		probe := randomHealthProbe(rand.New(rand.NewSource(42)))
+		var all []probeAnswer
+		for range 20 {
+			delay, err := probe("svc")
+			if delay < 100*time.Millisecond || delay >= 900*time.Millisecond {
+				t.Errorf("delay %v outside 100-900ms", delay)
+			}
+			all = append(all, probeAnswer{delay: delay, err: err})
+		}
+		return all
+	}
+
+	first, second := draws(), draws()
+	for i := range first {
+		if first[i].delay != second[i].delay || (first[i].err == nil) != (second[i].err == nil) {
+			t.Fatalf("draw %d differs with the same seed: %+v vs %+v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestMonitorServices(t *testing.T) {
+	expectPanic(t, "MonitorServices with no interval", func() {
+		MonitorServices(context.Background(), demoServices, 0, time.Second)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reports := MonitorServices(ctx, []string{"a", "b"}, 10*time.Millisecond, time.Second, WithHealthProbe(fakeProbe(nil)))
+
+	for i := range 3 {
+		select {
+		case report := <-reports:
+			if report.Healthy != 2 {
+				t.Errorf("report %d = %+v, want 2 healthy", i, report)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("report %d did not arrive", i)
+		}
+	}
+	cancel()
+	closesWithin(t, "MonitorServices reports after cancel", reports)
+}
+
+func TestSelectTimeoutComparisonCancelled(t *testing.T) {
+	quietOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := SelectTimeoutComparison(ctx)
+	if !summary.Cancelled || summary.Sequential != 0 || summary.Completed != 0 {
+		t.Errorf("summary = %+v, want cancelled before any check finished", summary)
+	}
+	if verdict := summary.Details["verdict"]; verdict != "CRITICAL" {
+		t.Errorf("verdict = %v, want CRITICAL", verdict)
+	}
+}
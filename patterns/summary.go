@@ -0,0 +1,87 @@
+package patterns
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"time"
+)
+
+// RunSummary is the outcome of a pattern's non-interactive demo: how much
+// work the concurrent version completed and how long it took next to the
+// sequential baseline, if the pattern has one. A pattern without a baseline
+// reports the length of its only run as Concurrent. Details holds figures
+// that only make sense for that pattern, keyed by their JSON name. Cancelled
+// is set when the run was stopped early, in which case the figures only
+// cover the work done until then.
+type RunSummary struct {
+	Pattern    string
+	Completed  int
+	Workers    int
+	Concurrent time.Duration
+	Sequential time.Duration
+	Details    map[string]any
+	Cancelled  bool
+}
+
+// Speedup is how many times faster the concurrent version ran than the
+// sequential one, or 0 without a sequential baseline.
+func (s RunSummary) Speedup() float64 {
+	if s.Sequential == 0 || s.Concurrent == 0 {
+		return 0
+	}
+	return float64(s.Sequential) / float64(s.Concurrent)
+}
+
+// MarshalJSON flattens the summary into one object with durations in
+// milliseconds, for example
+// {"completed":10,"concurrentMs":352.4,"pattern":"worker-pool","sequentialMs":1002.5,"speedup":2.84,"workers":3}.
+// Workers and the sequential figures are left out when they do not apply.
+func (s RunSummary) MarshalJSON() ([]byte, error) {
+	fields := maps.Clone(s.Details)
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+
+	fields["pattern"] = s.Pattern
+	fields["completed"] = s.Completed
+	fields["concurrentMs"] = milliseconds(s.Concurrent)
+	if s.Workers > 0 {
+		fields["workers"] = s.Workers
+	}
+	if s.Sequential > 0 {
+		fields["sequentialMs"] = milliseconds(s.Sequential)
+		fields["speedup"] = s.Speedup()
+	}
+	if s.Cancelled {
+		fields["cancelled"] = true
+	}
+	return json.Marshal(fields)
+}
+
+// cancelled reports whether ctx is done, and if so tells the user the rest
+// of the demo is being skipped and marks summary as cancelled.
+func cancelled(ctx context.Context, summary *RunSummary) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	console.Println("\n🛑 Cancelled: skipping the rest of the demo")
+	summary.Cancelled = true
+	return true
+}
+
+// sleepContext pauses for d, returning ctx's error early if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
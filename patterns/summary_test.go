@@ -0,0 +1,123 @@
+package patterns
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRunSummarySpeedup(t *testing.T) {
+	tests := []struct {
+		concurrent, sequential time.Duration
+		want                   float64
+	}{
+		{time.Second, 3 * time.Second, 3},
+		{2 * time.Second, time.Second, 0.5},
+		{time.Second, 0, 0},
+		{0, time.Second, 0},
+	}
+	for _, tt := range tests {
+		s := RunSummary{Concurrent: tt.concurrent, Sequential: tt.sequential}
+		if got := s.Speedup(); got != tt.want {
+			t.Errorf("Speedup() with %v/%v = %v, want %v", tt.sequential, tt.concurrent, got, tt.want)
+		}
+	}
+}
+
+func TestRunSummaryJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary RunSummary
+		want    map[string]any
+	}{
+		{
+			name: "with baseline",
+			summary: RunSummary{
+				Pattern:    "worker-pool",
+				Completed:  10,
+				Workers:    3,
+				Concurrent: 500 * time.Millisecond,
+				Sequential: 1500 * time.Millisecond,
+			},
+			want: map[string]any{
+				"pattern":      "worker-pool",
+				"completed":    10.0,
+				"workers":      3.0,
+				"concurrentMs": 500.0,
+				"sequentialMs": 1500.0,
+				"speedup":      3.0,
+			},
+		},
+		{
+			name: "details and cancelled",
+			summary: RunSummary{
+				Pattern:    "circuit-breaker",
+				Completed:  4,
+				Concurrent: 250 * time.Millisecond,
+				Details:    map[string]any{"blocked": 2},
+				Cancelled:  true,
+			},
+			want: map[string]any{
+				"pattern":      "circuit-breaker",
+				"completed":    4.0,
+				"concurrentMs": 250.0,
+				"blocked":      2.0,
+				"cancelled":    true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got fields %v, want %v", got, tt.want)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelledMarksSummary(t *testing.T) {
+	buf := captureOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var summary RunSummary
+	if cancelled(ctx, &summary) || summary.Cancelled {
+		t.Fatalf("cancelled reported true before ctx was cancelled")
+	}
+	cancel()
+	if !cancelled(ctx, &summary) || !summary.Cancelled {
+		t.Fatalf("cancelled did not mark the summary once ctx was cancelled")
+	}
+	if buf.Len() == 0 {
+		t.Errorf("cancelled printed nothing")
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("sleepContext = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := sleepContext(ctx, time.Minute); err != context.Canceled {
+		t.Errorf("sleepContext on a cancelled ctx = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepContext on a cancelled ctx took %v", elapsed)
+	}
+}
@@ -1,83 +1,1480 @@
 package patterns
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func WorkerPool() {
-	fmt.Println("=== Worker Pool Pattern ===")
-	fmt.Println("Multiple workers processing jobs from a shared channel")
-	fmt.Println()
+// Pool runs a set of workers that apply the same function to every submitted
+// job. Results come out in completion order, not submission order.
+type Pool[J, R any] struct {
+	work           func(ctx context.Context, job J) R
+	failed         func(job J, err error) R
+	hasError       func(result R) bool
+	jobs           chan J
+	output         chan R // results from the workers, buffered by forward
+	results        chan R
+	ctx            context.Context
+	cancel         context.CancelFunc
+	quit           chan struct{}
+	quitOnce       sync.Once
+	completed      atomic.Int64
+	queued         atomic.Int64 // jobs submitted but not yet picked up
+	active         atomic.Int64 // jobs being worked on
+	live           atomic.Int64 // running workers
+	nextID         atomic.Int64
+	stats          map[int]*WorkerStats
+	statsMutex     sync.Mutex
+	onProgress     func(done, total int)
+	progressTotal  int
+	priority       priorityQueue[J]
+	prioritySeq    int64
+	priorityClosed bool
+	dispatching    bool
+	dispatched     chan struct{}
+	priorityCond   *sync.Cond
+	priorityMutex  sync.Mutex
+	minWorkers     int
+	maxWorkers     int
+	scaleThreshold int
+	idleTimeout    time.Duration
+	jobTimeout     time.Duration
+	retries        int
+	backoff        time.Duration
+	wg             sync.WaitGroup
+	closed         bool
+	mutex          sync.RWMutex
+}
+
+// WorkerStats records how much work a single worker has done.
+type WorkerStats struct {
+	Jobs int
+	Busy time.Duration
+}
+
+// PoolStats is a point-in-time view of the jobs moving through a pool.
+type PoolStats struct {
+	Queued    int // submitted, waiting for a worker
+	Active    int // being worked on
+	Completed int // finished, successfully or not
+}
+
+// ErrPoolStopped is returned by SubmitContext once the pool has been
+// cancelled or shut down.
+var ErrPoolStopped = errors.New("worker pool stopped")
+
+// ErrJobTimeout is reported for a job that runs longer than the pool's job
+// timeout.
+var ErrJobTimeout = errors.New("worker pool job timed out")
+
+// JobResult pairs a job with the value or error it produced.
+type JobResult[J, R any] struct {
+	Job   J
+	Value R
+	Err   error
+}
+
+// NewPool starts workers goroutines that process jobs with fn.
+func NewPool[J, R any](workers int, fn func(J) R, opts ...PoolOption) *Pool[J, R] {
+	work := func(_ context.Context, job J) R {
+		return fn(job)
+	}
+	return newPool(workers, work, nil, nil, opts)
+}
+
+// NewPoolAuto is NewPool with one worker per CPU the Go scheduler may use,
+// as reported by runtime.GOMAXPROCS. Prefer it for CPU-bound jobs, where
+// more workers than CPUs only adds scheduling overhead; jobs that mostly wait
+// on I/O usually want a fixed, larger count. WithWorkers overrides the
+// choice.
+func NewPoolAuto[J, R any](fn func(J) R, opts ...PoolOption) *Pool[J, R] {
+	workers := newPoolConfig(opts).workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return NewPool(workers, fn, opts...)
+}
+
+// newPool starts the workers. failed turns a job that could not produce a
+// value, such as one that panicked, into a result; if it is nil such jobs
+// produce no result at all. hasError, if set, reports whether a result
+// records a failure, which makes the job eligible for a retry.
+func newPool[J, R any](workers int, fn func(context.Context, J) R, failed func(J, error) R, hasError func(R) bool, opts []PoolOption) *Pool[J, R] {
+	if workers < 1 {
+		panic("patterns: worker pool needs at least one worker")
+	}
+
+	cfg := newPoolConfig(opts)
+	ctx, cancel := context.WithCancel(cfg.ctx)
+	p := &Pool[J, R]{
+		work:           fn,
+		failed:         failed,
+		hasError:       hasError,
+		jobs:           make(chan J, cfg.queueSize),
+		output:         make(chan R),
+		results:        make(chan R),
+		ctx:            ctx,
+		cancel:         cancel,
+		quit:           make(chan struct{}),
+		minWorkers:     workers,
+		maxWorkers:     max(workers, cfg.maxWorkers),
+		scaleThreshold: cfg.scaleThreshold,
+		idleTimeout:    cfg.idleTimeout,
+		jobTimeout:     cfg.jobTimeout,
+		retries:        cfg.retries,
+		backoff:        cfg.backoff,
+		stats:          make(map[int]*WorkerStats),
+		onProgress:     cfg.onProgress,
+		progressTotal:  cfg.progressTotal,
+		dispatched:     make(chan struct{}),
+	}
+	p.priorityCond = sync.NewCond(&p.priorityMutex)
+
+	// The pool itself holds one count on wg until the job queue is closed,
+	// so workers can be added safely while jobs may still be submitted.
+	p.wg.Add(1)
+	for w := 1; w <= workers; w++ {
+		p.live.Add(1)
+		p.wg.Add(1)
+		go p.worker(p.newWorkerID())
+	}
+
+	go func() {
+		<-p.ctx.Done()
+		p.closePriority()
+		p.closeJobs()
+	}()
+	go p.forward()
+	go func() {
+		p.wg.Wait()
+		close(p.output)
+
+		// Discard whatever was still queued when the pool was stopped.
+		for range p.jobs {
+			p.queued.Add(-1)
+		}
+	}()
+	return p
+}
+
+// NewTryPool is NewPool for a function that can fail. Every job yields a
+// JobResult, so failed jobs are reported alongside successful ones without
+// holding them up. A job that panics is reported with a *PanicError.
+func NewTryPool[J, R any](workers int, fn func(J) (R, error), opts ...PoolOption) *Pool[J, JobResult[J, R]] {
+	return NewContextPool(workers, func(_ context.Context, job J) (R, error) {
+		return fn(job)
+	}, opts...)
+}
+
+// NewContextPool is NewTryPool for a function that takes a context. The
+// context is cancelled when the pool is, or when the job exceeds the pool's
+// job timeout; fn should return promptly once it is done.
+func NewContextPool[J, R any](workers int, fn func(context.Context, J) (R, error), opts ...PoolOption) *Pool[J, JobResult[J, R]] {
+	work := func(ctx context.Context, job J) JobResult[J, R] {
+		value, err := fn(ctx, job)
+		return JobResult[J, R]{Job: job, Value: value, Err: err}
+	}
+	failed := func(job J, err error) JobResult[J, R] {
+		return JobResult[J, R]{Job: job, Err: err}
+	}
+	hasError := func(result JobResult[J, R]) bool {
+		return result.Err != nil
+	}
+	return newPool(workers, work, failed, hasError, opts)
+}
+
+// ForEachN calls fn for every item with at most n calls running at once and
+// returns when they have all finished. A buffered channel acts as the
+// semaphore: each goroutine must put a token in it before calling fn.
+func ForEachN[T any](items []T, n int, fn func(T)) {
+	if n < 1 {
+		panic("patterns: ForEachN needs a limit of at least one")
+	}
+
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// Stream processes every job received from in with a new pool of workers.
+// The returned channel closes once in has been closed and all of its jobs
+// are done.
+func Stream[J, R any](workers int, in <-chan J, fn func(J) R, opts ...PoolOption) <-chan R {
+	pool := NewPool(workers, fn, opts...)
+	pool.SubmitFrom(in)
+	return pool.Results()
+}
+
+// ProcessAll runs fn over jobs with the given number of workers and returns
+// the results in the same order as jobs. If any job fails, the errors are
+// joined in job order; the results of failed jobs are left as zero values.
+func ProcessAll[J, R any](workers int, jobs []J, fn func(J) (R, error)) ([]R, error) {
+	type indexed struct {
+		index int
+		job   J
+	}
+
+	pool := NewTryPool(workers, func(job indexed) (R, error) {
+		return fn(job.job)
+	})
+	go func() {
+		for i, job := range jobs {
+			pool.Submit(indexed{index: i, job: job})
+		}
+		pool.Close()
+	}()
+
+	results := make([]R, len(jobs))
+	errs := make([]error, len(jobs))
+	for result := range pool.Results() {
+		results[result.Job.index] = result.Value
+		errs[result.Job.index] = result.Err
+	}
+	return results, errors.Join(errs...)
+}
+
+// worker processes jobs until the queue is closed. Between jobs it checks
+// whether the pool has been cancelled or shut down, so jobs that have not
+// started yet are discarded rather than run. A panicking job is recovered so
+// the worker carries on with the next one.
+func (p *Pool[J, R]) worker(id int) {
+	retired := false
+	defer func() {
+		if !retired {
+			p.live.Add(-1)
+		}
+		p.wg.Done()
+	}()
+
+	// Workers beyond the minimum retire after sitting idle for idleTimeout.
+	var idle *time.Timer
+	var idleC <-chan time.Time
+	if p.maxWorkers > p.minWorkers {
+		idle = time.NewTimer(p.idleTimeout)
+		defer idle.Stop()
+		idleC = idle.C
+	}
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.quit:
+			return
+		case <-idleC:
+			if retired = p.retire(); retired {
+				return
+			}
+			idle.Reset(p.idleTimeout)
+		case job, ok := <-p.jobs:
+			if !ok {
+				return
+			}
+			p.queued.Add(-1)
+			if p.stopping() {
+				return
+			}
+
+			start := time.Now()
+			result, ok := p.process(job)
+			p.recordJob(id, time.Since(start))
+
+			if ok {
+				select {
+				case p.output <- result:
+				case <-p.ctx.Done():
+					return
+				}
+			}
+			if idle != nil {
+				idle.Reset(p.idleTimeout)
+			}
+		}
+	}
+}
+
+// process runs a single job. It reports false if the job failed and the pool
+// has no way to turn the failure into a result.
+func (p *Pool[J, R]) process(job J) (R, bool) {
+	p.active.Add(1)
+	result, err := p.runWithRetries(job)
+	p.active.Add(-1) // before counting it complete, so Stats never sees it twice
+	p.reportProgress()
+
+	if err != nil {
+		if p.failed == nil {
+			return result, false
+		}
+		result = p.failed(job, err)
+	}
+	return result, true
+}
+
+// runWithRetries runs a job, running it again up to the pool's retry limit
+// while it fails. The wait before each retry starts at the backoff and
+// doubles every time. The outcome of the last attempt is returned.
+func (p *Pool[J, R]) runWithRetries(job J) (R, error) {
+	delay := p.backoff
+	for attempt := 0; ; attempt++ {
+		result, err := p.run(job)
+		failed := err != nil || (p.hasError != nil && p.hasError(result))
+		if !failed || attempt >= p.retries {
+			return result, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-p.ctx.Done():
+			timer.Stop()
+			return result, err
+		}
+		delay *= 2
+	}
+}
+
+// run executes a single job, giving up with ErrJobTimeout if a job timeout is
+// set and the job takes longer. The job's context is cancelled at that point,
+// but a function that ignores it keeps running in the background until it
+// returns; its result is then discarded.
+func (p *Pool[J, R]) run(job J) (R, error) {
+	if p.jobTimeout <= 0 {
+		var result R
+		err := protect(func() error {
+			result = p.work(p.ctx, job)
+			return nil
+		}, true)
+		return result, err
+	}
+
+	ctx, cancel := context.WithTimeoutCause(p.ctx, p.jobTimeout, ErrJobTimeout)
+	defer cancel()
+
+	type outcome struct {
+		result R
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var o outcome
+		o.err = protect(func() error {
+			o.result = p.work(ctx, job)
+			return nil
+		}, true)
+		done <- o
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		var zero R
+		return zero, context.Cause(ctx)
+	}
+}
+
+// reportProgress counts a finished job and passes the new total to the
+// progress callback. The count is taken atomically and the callback runs
+// with no lock held, so a slow callback only delays the worker calling it.
+func (p *Pool[J, R]) reportProgress() {
+	done := int(p.completed.Add(1))
+	if p.onProgress != nil {
+		p.onProgress(done, p.progressTotal)
+	}
+}
+
+// scaleUp starts another worker unless the pool is already at its maximum.
+// The caller must hold the read lock so the queue cannot close meanwhile.
+func (p *Pool[J, R]) scaleUp() {
+	for {
+		n := p.live.Load()
+		if n >= int64(p.maxWorkers) {
+			return
+		}
+		if p.live.CompareAndSwap(n, n+1) {
+			p.wg.Add(1)
+			go p.worker(p.newWorkerID())
+			return
+		}
+	}
+}
+
+// retire claims the right for an idle worker to exit, which it only gets
+// while the pool is above its minimum size.
+func (p *Pool[J, R]) retire() bool {
+	for {
+		n := p.live.Load()
+		if n <= int64(p.minWorkers) {
+			return false
+		}
+		if p.live.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+func (p *Pool[J, R]) newWorkerID() int {
+	id := int(p.nextID.Add(1))
+
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+	p.stats[id] = &WorkerStats{}
+	return id
+}
+
+func (p *Pool[J, R]) recordJob(id int, busy time.Duration) {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+
+	stats := p.stats[id]
+	stats.Jobs++
+	stats.Busy += busy
+}
+
+// WorkerStats returns a snapshot of the work done so far by every worker the
+// pool has started, keyed by worker id. Uneven job counts point to workers
+// being starved or held up by slow jobs.
+func (p *Pool[J, R]) WorkerStats() map[int]WorkerStats {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+
+	snapshot := make(map[int]WorkerStats, len(p.stats))
+	for id, stats := range p.stats {
+		snapshot[id] = *stats
+	}
+	return snapshot
+}
+
+// Workers returns the number of workers currently running.
+func (p *Pool[J, R]) Workers() int {
+	return int(p.live.Load())
+}
+
+// forward passes results from the workers on to Results, holding them in a
+// slice while nobody is reading so that a slow reader never stops a worker
+// from moving on to its next job. Undelivered results are dropped if the
+// pool is cancelled.
+func (p *Pool[J, R]) forward() {
+	defer p.cancel() // release the context once everything is delivered
+	defer close(p.results)
+
+	var pending []R
+	in := p.output
+	for in != nil || len(pending) > 0 {
+		var out chan R
+		var next R
+		if len(pending) > 0 {
+			out = p.results
+			next = pending[0]
+		}
+
+		select {
+		case result, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+			pending = append(pending, result)
+		case out <- next:
+			var zero R
+			pending[0] = zero
+			pending = pending[1:]
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// stopping reports whether the pool was cancelled or shut down.
+func (p *Pool[J, R]) stopping() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return p.ctx.Err() != nil
+	}
+}
+
+// Submit hands job to the pool, blocking until there is room in the queue or
+// a free worker takes it. If the pool is cancelled or shut down, the job is
+// discarded. It panics if the pool has been closed.
+func (p *Pool[J, R]) Submit(job J) {
+	p.submit(context.Background(), job, true)
+}
+
+// SubmitContext is Submit that gives up when ctx is done, returning ctx's
+// error, and reports ErrPoolStopped if the pool is cancelled or shut down.
+func (p *Pool[J, R]) SubmitContext(ctx context.Context, job J) error {
+	return p.submit(ctx, job, true)
+}
+
+// TrySubmit queues job only if that can be done without blocking, and
+// reports whether it did. A false result lets callers shed load instead of
+// waiting on a full queue.
+func (p *Pool[J, R]) TrySubmit(job J) bool {
+	return p.submit(context.Background(), job, false) == nil
+}
+
+// SubmitFrom submits every job received from in, in the background, and
+// closes the pool once in is closed. It stops reading from in early if the
+// pool is cancelled or shut down. No other jobs may be submitted after in is
+// closed.
+func (p *Pool[J, R]) SubmitFrom(in <-chan J) {
+	go func() {
+		for job := range in {
+			if p.SubmitContext(context.Background(), job) != nil {
+				return
+			}
+		}
+		p.Close()
+	}()
+}
+
+var errQueueFull = errors.New("worker pool queue is full")
+
+func (p *Pool[J, R]) submit(ctx context.Context, job J, wait bool) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.stopping() {
+		return ErrPoolStopped
+	}
+	if p.closed {
+		panic("patterns: Submit on closed worker pool")
+	}
+
+	if p.queued.Add(1) > int64(p.scaleThreshold) && p.maxWorkers > p.minWorkers {
+		p.scaleUp()
+	}
+
+	if !wait {
+		select {
+		case p.jobs <- job:
+			return nil
+		default:
+			p.queued.Add(-1)
+			return errQueueFull
+		}
+	}
+
+	select {
+	case p.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		return ctx.Err()
+	case <-p.ctx.Done():
+		p.queued.Add(-1)
+		return ErrPoolStopped
+	case <-p.quit:
+		p.queued.Add(-1)
+		return ErrPoolStopped
+	}
+}
+
+// Results returns the channel of finished results. It is closed once the
+// pool has been closed and every submitted job has been processed. Results
+// are held inside the pool until they are read, so it is fine to submit
+// everything and close the pool first, but the channel must eventually be
+// drained to release the pool's goroutines.
+func (p *Pool[J, R]) Results() <-chan R {
+	return p.results
+}
+
+// Close signals that no more jobs will be submitted and waits for the
+// workers to finish the ones already handed out.
+func (p *Pool[J, R]) Close() {
+	p.closePriority()
+	p.closeJobs()
+	p.wg.Wait()
+}
+
+func (p *Pool[J, R]) closeJobs() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+		p.wg.Done() // release the pool's own count taken in newPool
+	}
+}
+
+// Shutdown stops the pool from starting any more jobs and waits for the ones
+// already running to finish. If ctx is done first, the pool is cancelled,
+// results still in flight are dropped and ctx's error is returned.
+func (p *Pool[J, R]) Shutdown(ctx context.Context) error {
+	// Release blocked Submit calls before waiting for the lock they hold.
+	p.quitOnce.Do(func() { close(p.quit) })
+	p.closePriority()
+	p.closeJobs()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// CloseWithTimeout is Close that waits at most d for the queued jobs to be
+// worked off. It reports whether everything drained in time; if not, the pool
+// is cancelled, discarding the jobs that have not started. Jobs already
+// running finish in the background and their results are dropped.
+func (p *Pool[J, R]) CloseWithTimeout(d time.Duration) (drained bool) {
+	done := make(chan struct{})
+	go func() {
+		p.Close()
+		close(done)
+	}()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		p.cancel()
+		return false
+	}
+}
+
+// Stats reports how many jobs are queued, running and finished. The counts
+// are read one after another without stopping the pool, so a job moving
+// between states may briefly be missing from, but never counted twice in,
+// the totals. Active never exceeds the number of workers.
+func (p *Pool[J, R]) Stats() PoolStats {
+	return PoolStats{
+		Queued:    int(p.queued.Load()),
+		Active:    int(p.active.Load()),
+		Completed: int(p.completed.Load()),
+	}
+}
+
+// Completed returns the number of jobs the workers have finished.
+func (p *Pool[J, R]) Completed() int {
+	return int(p.completed.Load())
+}
+
+// PoolOption configures a worker pool created by NewPool or NewTryPool.
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	ctx            context.Context
+	maxWorkers     int
+	scaleThreshold int
+	idleTimeout    time.Duration
+	jobTimeout     time.Duration
+	queueSize      int
+	onProgress     func(done, total int)
+	progressTotal  int
+	retries        int
+	backoff        time.Duration
+	workers        int
+}
+
+func newPoolConfig(opts []PoolOption) poolConfig {
+	cfg := poolConfig{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithPoolContext ties the pool to ctx. Once ctx is done the workers stop
+// between jobs, unstarted jobs are discarded and the results channel closes.
+func WithPoolContext(ctx context.Context) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithAutoscale lets the pool grow from the worker count given to NewPool up
+// to max workers. Another worker is started whenever more than threshold jobs
+// are waiting to be picked up, and workers above the minimum exit again after
+// idling for idleTimeout.
+func WithAutoscale(max, threshold int, idleTimeout time.Duration) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.maxWorkers = max
+		cfg.scaleThreshold = threshold
+		cfg.idleTimeout = idleTimeout
+	}
+}
+
+// WithJobTimeout abandons any job that runs longer than d, reporting
+// ErrJobTimeout for it so the worker can move on. The job's context is
+// cancelled too, but only a function given to NewContextPool can see that;
+// any other keeps running in the background until it returns.
+func WithJobTimeout(d time.Duration) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.jobTimeout = d
+	}
+}
+
+// WithQueueSize lets up to n submitted jobs wait for a free worker. Without
+// it Submit blocks until a worker takes the job, and TrySubmit only succeeds
+// when a worker is already waiting.
+func WithQueueSize(n int) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.queueSize = n
+	}
+}
+
+// WithOnProgress calls fn after every job finishes with the number of jobs
+// done so far and the expected total, which is 0 when it is not known up
+// front, for example when jobs are streamed in. fn runs on the worker that
+// finished the job without holding any of the pool's locks, so calls from
+// different workers can overlap and arrive slightly out of order; fn must be
+// safe for concurrent use. A slow fn delays only the worker running it.
+func WithOnProgress(total int, fn func(done, total int)) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.progressTotal = total
+		cfg.onProgress = fn
+	}
+}
+
+// WithRetries runs a failed job up to retries more times before reporting
+// the error from its last attempt. A job has failed if it panicked, timed
+// out or, in a pool from NewTryPool or NewContextPool, returned an error.
+// The pool waits backoff before the first retry and twice as long before
+// each one after that.
+func WithRetries(retries int, backoff time.Duration) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.retries = retries
+		cfg.backoff = backoff
+	}
+}
+
+// WithWorkers sets the number of workers started by NewPoolAuto in place of
+// the CPU count. Other constructors take the count as an argument.
+func WithWorkers(n int) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.workers = n
+	}
+}
+
+// WorkerPool runs the interactive worker pool menu. Cancelling ctx stops the
+// comparison early and leaves the menu.
+func WorkerPool(ctx context.Context) {
+	workerPoolHeader()
+	settings := defaultWPDemoSettings()
+	for {
+		console.Println("Worker Pool Demo Options:")
+		console.Println("1. ⚡ Concurrent vs sequential (comparison)")
+		console.Println("2. ⚠️  Failing jobs")
+		console.Println("3. 🛑 Cancellation and shutdown")
+		console.Println("4. 💥 Panicking jobs")
+		console.Println("5. 📈 Autoscaling workers")
+		console.Println("6. ⏱️  Job timeouts")
+		console.Println("7. 🚧 Bounded queue and load shedding")
+		console.Println("8. 📋 Process a whole slice")
+		console.Println("9. 🌊 Streaming jobs")
+		console.Println("10. 🚨 Priority jobs")
+		console.Println("11. 🎛️  Bounded parallelism without a pool")
+		console.Println("12. 🔁 Retrying flaky jobs")
+		console.Println("13. 🧮 CPU-bound work sized to the machine")
+		console.Println("14. ⚙️  Configure workers and jobs")
+		console.Println("0. Back to main menu")
+		console.Print("Select demo (0-14): ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+		console.Println()
+
+		switch choice {
+		case 1:
+			runWorkerPoolComparison(ctx, settings)
+		case 2:
+			runWorkerPoolErrorDemo()
+		case 3:
+			runWorkerPoolCancelDemo()
+		case 4:
+			runWorkerPoolPanicDemo()
+		case 5:
+			runWorkerPoolAutoscaleDemo()
+		case 6:
+			runWorkerPoolTimeoutDemo()
+		case 7:
+			runWorkerPoolQueueDemo()
+		case 8:
+			runProcessAllDemo()
+		case 9:
+			runWorkerPoolStreamDemo()
+		case 10:
+			runWorkerPoolPriorityDemo()
+		case 11:
+			runForEachNDemo()
+		case 12:
+			runWorkerPoolRetryDemo()
+		case 13:
+			runWorkerPoolCPUDemo()
+		case 14:
+			settings = configureWPDemoSettings(settings)
+			console.Println()
+			runWorkerPoolComparison(ctx, settings)
+		case 0:
+			return
+		default:
+			console.Println("Invalid choice. Please try again.")
+			console.Println()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		console.Println("\nPress Enter to continue...")
+		fmt.Scanf("\n")
+		console.Println()
+	}
+}
+
+// WorkerPoolComparison runs the worker pool comparison with the default
+// settings, without asking for input. Cancelling ctx cuts it short.
+func WorkerPoolComparison(ctx context.Context) RunSummary {
+	workerPoolHeader()
+	return runWorkerPoolComparison(ctx, defaultWPDemoSettings())
+}
+
+func workerPoolHeader() {
+	console.Println("=== Worker Pool Pattern ===")
+	console.Println("Multiple workers processing jobs from a shared channel")
+	console.Println()
+}
+
+// wpDemoSettings holds the user's sizing for the worker pool comparison.
+type wpDemoSettings struct {
+	workers int
+	jobs    int
+}
+
+func defaultWPDemoSettings() wpDemoSettings {
+	return wpDemoSettings{workers: 3, jobs: 10}
+}
+
+func configureWPDemoSettings(s wpDemoSettings) wpDemoSettings {
+	console.Println("⚙️  === Configure Worker Pool ===")
+	console.Println("Press Enter to keep the current value")
+	console.Println()
+
+	s.workers = promptInt("Number of workers", s.workers, 1)
+	s.jobs = promptInt("Number of jobs", s.jobs, 0)
+
+	console.Printf("\n🔧 Comparison will run %d jobs on %d workers\n", s.jobs, s.workers)
+	return s
+}
+
+func runWorkerPoolComparison(ctx context.Context, settings wpDemoSettings) RunSummary {
+	summary := RunSummary{Pattern: "worker-pool", Workers: settings.workers}
 
 	// Run concurrent version
-	fmt.Println("Running CONCURRENT version...")
+	console.Detailln("Running CONCURRENT version...")
 	concurrentStart := time.Now()
-	runWorkerPoolConcurrent()
-	concurrentDuration := time.Since(concurrentStart)
+	results := runWorkerPoolConcurrent(ctx, settings.workers, settings.jobs)
+	summary.Concurrent = time.Since(concurrentStart)
+	summary.Completed = len(results)
+	if cancelled(ctx, &summary) {
+		return summary
+	}
 
-	fmt.Printf("\nCONCURRENT version took: %v\n\n", concurrentDuration)
+	console.Printf("\nCONCURRENT version took: %v\n\n", summary.Concurrent)
 
 	// Run sequential version for comparison
-	fmt.Println("Running SEQUENTIAL version for comparison...")
+	console.Detailln("Running SEQUENTIAL version for comparison...")
 	sequentialStart := time.Now()
-	runWorkerPoolSequential()
-	sequentialDuration := time.Since(sequentialStart)
+	runWorkerPoolSequential(ctx, settings.jobs)
+	summary.Sequential = time.Since(sequentialStart)
+	if cancelled(ctx, &summary) {
+		return summary
+	}
 
-	fmt.Printf("\nSEQUENTIAL version took: %v\n", sequentialDuration)
-	fmt.Printf("Speedup: %.2fx faster with concurrency!\n\n", float64(sequentialDuration)/float64(concurrentDuration))
+	console.Printf("\nSEQUENTIAL version took: %v\n", summary.Sequential)
+	console.Printf("Speedup: %.2fx faster with concurrency!\n\n", summary.Speedup())
+	return summary
 }
 
-func runWorkerPoolConcurrent() {
+func runWorkerPoolConcurrent(ctx context.Context, numWorkers, numJobs int) []int {
 	
-	const numWorkers = 3
-	const numJobs = 10
+	pool := NewPool(numWorkers, processJob, WithOnProgress(numJobs, newProgressPrinter()), WithPoolContext(ctx))
 	
-	jobs := make(chan int, numJobs)
-	results := make(chan int, numJobs)
+	// Send jobs, then close the pool once they have all been handed out
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for j := 1; j <= numJobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
 	
-	var wg sync.WaitGroup
-	
-	// Start workers
-	for w := 1; w <= numWorkers; w++ {
-		wg.Add(1)
-		go worker(w, jobs, results, &wg)
+	// Collect completed jobs
+	var results []int
+	for result := range pool.Results() {
+		results = append(results, result)
 	}
+	// A cancelled pool closes Results early; wait for the jobs still running
+	// so their progress updates don't land after the demo has moved on.
+	<-closed
 	
-	// Send jobs
-	for j := 1; j <= numJobs; j++ {
-		jobs <- j
+	console.Printf("Completed %d jobs with %d workers\n", len(results), numWorkers)
+	printWorkerStats(pool.WorkerStats())
+	return results
+}
+
+// newProgressPrinter returns a progress callback that redraws a progress
+// bar in place, ending the line when done. Updates can arrive out of order
+// from different workers, so any that would move the bar backwards are
+// skipped.
+func newProgressPrinter() func(done, total int) {
+	const width = 20
+	var mutex sync.Mutex
+	var shown int
+	return func(done, total int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if done <= shown {
+			return
+		}
+		shown = done
+
+		console.Detailf("\r[%-*s] %d/%d", width, strings.Repeat("#", done*width/total), done, total)
+		if done == total {
+			console.Detailln()
+		}
 	}
-	close(jobs)
-	
-	// Wait for all workers to finish
-	wg.Wait()
-	close(results)
-	
-	// Count completed jobs
-	var completed int
-	for range results {
-		completed++
+}
+
+func printWorkerStats(stats map[int]WorkerStats) {
+	for _, id := range slices.Sorted(maps.Keys(stats)) {
+		console.Printf("  worker %d: %d jobs, busy %v\n", id, stats[id].Jobs, stats[id].Busy.Round(time.Millisecond))
 	}
-	
-	fmt.Printf("Completed %d jobs with %d workers\n", completed, numWorkers)
 }
 
-func runWorkerPoolSequential() {
+func runWorkerPoolErrorDemo() {
+	console.Println("⚠️  === Failing Jobs ===")
+	console.Println("Every third job fails; the errors are reported alongside the results")
+	console.Println()
+
+	const numWorkers = 3
 	const numJobs = 10
-	
-	for j := 1; j <= numJobs; j++ {
-		time.Sleep(100 * time.Millisecond) // Same work simulation as concurrent version
+
+	pool := NewTryPool(numWorkers, func(job int) (int, error) {
+		time.Sleep(100 * time.Millisecond) // Simulate work
+		if job%3 == 0 {
+			return 0, fmt.Errorf("job %d: upstream service rejected the request", job)
+		}
+		return job * job, nil
+	})
+
+	go func() {
+		for j := 1; j <= numJobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	var succeeded, failed int
+	for result := range pool.Results() {
+		if result.Err != nil {
+			failed++
+			console.Detailf("❌ %v\n", result.Err)
+			continue
+		}
+		succeeded++
+		console.Detailf("✅ job %d -> %d\n", result.Job, result.Value)
 	}
-	
-	fmt.Printf("Completed %d jobs sequentially\n", numJobs)
+
+	console.Printf("\n📊 Results: %d succeeded, %d failed\n", succeeded, failed)
 }
 
-func worker(id int, jobs <-chan int, results chan<- int, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for job := range jobs {
+func runWorkerPoolCancelDemo() {
+	console.Println("🛑 === Cancellation and Shutdown ===")
+	console.Println()
+
+	const numWorkers = 3
+	const numJobs = 10
+
+	// Cancel the whole run through its context
+	console.Println("Cancelling the pool's context after 250ms...")
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	pool := NewPool(numWorkers, simulateJob, WithPoolContext(ctx))
+	go func() {
+		for j := 1; j <= numJobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+	for range pool.Results() {
+	}
+	console.Printf("⏹️  Stopped after %v with %d/%d jobs completed\n",
+		time.Since(start).Round(time.Millisecond), pool.Completed(), numJobs)
+
+	// Graceful shutdown: let running jobs finish, skip the rest
+	console.Println("\nShutting down a second pool after 150ms...")
+	start = time.Now()
+	drainPool := NewPool(numWorkers, simulateJob)
+	go func() {
+		for j := 1; j <= numJobs; j++ {
+			drainPool.Submit(j)
+		}
+	}()
+	go func() {
+		for range drainPool.Results() {
+		}
+	}()
+
+	time.Sleep(150 * time.Millisecond)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if err := drainPool.Shutdown(shutdownCtx); err != nil {
+		console.Printf("❌ Shutdown failed: %v\n", err)
+	}
+	console.Printf("⏹️  Shut down after %v with %d/%d jobs completed\n",
+		time.Since(start).Round(time.Millisecond), drainPool.Completed(), numJobs)
+
+	// Close with a deadline: let the queue drain, but not for too long
+	for _, timeout := range []time.Duration{250 * time.Millisecond, time.Second} {
+		console.Printf("\nClosing a full pool with a %v drain timeout...\n", timeout)
+		queuePool := NewPool(numWorkers, simulateJob, WithQueueSize(numJobs))
+		go func() {
+			for range queuePool.Results() {
+			}
+		}()
+		for j := 1; j <= numJobs; j++ {
+			queuePool.Submit(j)
+		}
+
+		if queuePool.CloseWithTimeout(timeout) {
+			console.Printf("✅ Drained all %d jobs in time\n", queuePool.Completed())
+		} else {
+			console.Printf("⏰ Timed out with %d/%d jobs completed; the rest were discarded\n",
+				queuePool.Completed(), numJobs)
+		}
+	}
+}
+
+func runWorkerPoolPanicDemo() {
+	console.Println("💥 === Panicking Jobs ===")
+	console.Println("Job 4 panics; the worker recovers and the other jobs still complete")
+	console.Println()
+
+	const numWorkers = 3
+	const numJobs = 10
+
+	pool := NewTryPool(numWorkers, func(job int) (int, error) {
 		time.Sleep(100 * time.Millisecond) // Simulate work
-		results <- job
+		if job == 4 {
+			var lookup map[string]int
+			lookup["job"] = job // assignment to a nil map
+		}
+		return job * job, nil
+	})
+
+	go func() {
+		for j := 1; j <= numJobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	var succeeded int
+	for result := range pool.Results() {
+		var panicErr *PanicError
+		if errors.As(result.Err, &panicErr) {
+			console.Detailf("💥 job %d panicked: %v\n", result.Job, panicErr.Value)
+			continue
+		}
+		succeeded++
+	}
+
+	console.Printf("\n📊 %d/%d jobs completed despite the panic\n", succeeded, numJobs)
+}
+
+func runWorkerPoolAutoscaleDemo() {
+	console.Println("📈 === Autoscaling Workers ===")
+	console.Println("A burst of 20 jobs grows the pool from 1 towards 6 workers;")
+	console.Println("extra workers retire after 200ms without work")
+	console.Println()
+
+	const burst = 20
+
+	pool := NewPool(1, simulateJob, WithAutoscale(6, 2, 200*time.Millisecond))
+	go func() {
+		for range pool.Results() {
+		}
+	}()
+
+	// Every job in the burst arrives at once
+	for j := 1; j <= burst; j++ {
+		go pool.Submit(j)
+	}
+
+	start := time.Now()
+	for i := 0; i < 12; i++ {
+		time.Sleep(100 * time.Millisecond)
+		stats := pool.Stats()
+		console.Detailf("[%5v] workers: %d  queued: %2d  active: %d  completed: %2d/%d\n",
+			time.Since(start).Round(100*time.Millisecond), pool.Workers(),
+			stats.Queued, stats.Active, stats.Completed, burst)
 	}
+	pool.Close()
+
+	console.Println("\nWork done by each worker:")
+	printWorkerStats(pool.WorkerStats())
+}
+
+func runWorkerPoolTimeoutDemo() {
+	console.Println("⏱️  === Job Timeouts ===")
+	console.Println("Every fourth job hangs for 1s; jobs are abandoned after 250ms")
+	console.Println()
+
+	const numWorkers = 3
+	const numJobs = 10
+
+	start := time.Now()
+	pool := NewContextPool(numWorkers, func(ctx context.Context, job int) (int, error) {
+		work := 100 * time.Millisecond
+		if job%4 == 0 {
+			work = time.Second
+		}
+		select {
+		case <-time.After(work): // Simulate work
+			return job * job, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}, WithJobTimeout(250*time.Millisecond))
+
+	go func() {
+		for j := 1; j <= numJobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	var succeeded, timedOut int
+	for result := range pool.Results() {
+		if errors.Is(result.Err, ErrJobTimeout) {
+			timedOut++
+			console.Detailf("⏱️  job %d timed out\n", result.Job)
+			continue
+		}
+		succeeded++
+		console.Detailf("✅ job %d -> %d\n", result.Job, result.Value)
+	}
+
+	console.Printf("\n📊 %d succeeded, %d timed out in %v\n",
+		succeeded, timedOut, time.Since(start).Round(time.Millisecond))
+}
+
+func runWorkerPoolQueueDemo() {
+	console.Println("🚧 === Bounded Queue and Load Shedding ===")
+	console.Println("Requests arrive every 25ms but 2 workers only clear one every 50ms;")
+	console.Println("once the 3-slot queue is full, new requests are rejected")
+	console.Println()
+
+	const numRequests = 15
+
+	pool := NewPool(2, simulateJob, WithQueueSize(3))
+	done := make(chan int)
+	go func() {
+		var completed int
+		for range pool.Results() {
+			completed++
+		}
+		done <- completed
+	}()
+
+	var shed int
+	for r := 1; r <= numRequests; r++ {
+		if pool.TrySubmit(r) {
+			console.Detailf("📥 request %2d queued\n", r)
+		} else {
+			shed++
+			console.Detailf("🚫 request %2d rejected: queue full\n", r)
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+	pool.Close()
+
+	console.Printf("\n📊 %d completed, %d shed\n", <-done, shed)
+}
+
+func runProcessAllDemo() {
+	console.Println("📋 === Process a Whole Slice ===")
+	console.Println("ProcessAll fetches every URL with 3 workers and returns the results in order")
+	console.Println()
+
+	urls := []string{
+		"https://example.com/users",
+		"https://example.com/orders",
+		"https://example.com/broken",
+		"https://example.com/products",
+		"https://example.com/timeout",
+		"https://example.com/reviews",
+	}
+
+	start := time.Now()
+	sizes, err := ProcessAll(3, urls, func(url string) (int, error) {
+		time.Sleep(100 * time.Millisecond) // Simulate the request
+		if strings.HasSuffix(url, "/broken") || strings.HasSuffix(url, "/timeout") {
+			return 0, fmt.Errorf("fetch %s: request failed", url)
+		}
+		return len(url) * 100, nil
+	})
+
+	for i, url := range urls {
+		console.Printf("  %-30s %5d bytes\n", url, sizes[i])
+	}
+	if err != nil {
+		console.Printf("\n❌ Some requests failed:\n%v\n", err)
+	}
+	console.Printf("\n⏱️  Took %v\n", time.Since(start).Round(time.Millisecond))
+}
+
+func runWorkerPoolStreamDemo() {
+	console.Println("🌊 === Streaming Jobs ===")
+	console.Println("Jobs trickle in from a producer of unknown length; the pool keeps")
+	console.Println("working until the producer closes its channel")
+	console.Println()
+
+	start := time.Now()
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for j := 1; j <= 12; j++ {
+			time.Sleep(time.Duration(j%4) * 20 * time.Millisecond) // Irregular arrivals
+			jobs <- j
+		}
+	}()
+
+	var completed int
+	for result := range Stream(3, jobs, simulateJob) {
+		completed++
+		console.Detailf("[%6v] ✅ job %d done\n", time.Since(start).Round(time.Millisecond), result)
+	}
+	console.Printf("\n📊 %d jobs processed before the stream ended\n", completed)
+}
+
+func runWorkerPoolPriorityDemo() {
+	console.Println("🚨 === Priority Jobs ===")
+	console.Println("8 routine jobs are queued for a single worker, then 3 urgent ones arrive;")
+	console.Println("the urgent jobs are dispatched as soon as the worker is free")
+	console.Println()
+
+	type task struct {
+		name     string
+		priority int
+	}
+
+	pool := NewPool(1, func(t task) task {
+		time.Sleep(50 * time.Millisecond) // Simulate work
+		return t
+	})
+
+	for i := 1; i <= 8; i++ {
+		pool.SubmitWithPriority(task{name: fmt.Sprintf("routine-%d", i), priority: 1}, 1)
+	}
+	time.Sleep(75 * time.Millisecond)
+	for i := 1; i <= 3; i++ {
+		pool.SubmitWithPriority(task{name: fmt.Sprintf("urgent-%d", i), priority: 10}, 10)
+	}
+	go pool.Close()
+
+	order := 1
+	for t := range pool.Results() {
+		icon := label("📄", "[NORMAL]")
+		if t.priority > 1 {
+			icon = label("🚨", "[URGENT]")
+		}
+		console.Printf("%2d. %s %s\n", order, icon, t.name)
+		order++
+	}
+}
+
+func runForEachNDemo() {
+	console.Println("🎛️  === Bounded Parallelism Without a Pool ===")
+	console.Println("ForEachN resizes 12 images with at most 4 running at a time")
+	console.Println()
+
+	const limit = 4
+	images := make([]string, 12)
+	for i := range images {
+		images[i] = fmt.Sprintf("photo-%02d.jpg", i+1)
+	}
+
+	var running, peak atomic.Int64
+	start := time.Now()
+	ForEachN(images, limit, func(image string) {
+		now := running.Add(1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond) // Simulate resizing
+		console.Detailf("🖼️  resized %s\n", image)
+		running.Add(-1)
+	})
+
+	console.Printf("\n📊 %d images in %v, peak concurrency %d (limit %d)\n",
+		len(images), time.Since(start).Round(time.Millisecond), peak.Load(), limit)
+}
+
+func runWorkerPoolRetryDemo() {
+	console.Println("🔁 === Retrying Flaky Jobs ===")
+	console.Println("Job N fails its first N%4 attempts; each job gets up to 2 retries")
+	console.Println()
+
+	const numJobs = 8
+
+	var mutex sync.Mutex
+	attempts := make(map[int]int)
+
+	pool := NewTryPool(3, func(job int) (int, error) {
+		mutex.Lock()
+		attempts[job]++
+		attempt := attempts[job]
+		mutex.Unlock()
+
+		time.Sleep(50 * time.Millisecond) // Simulate work
+		if attempt <= job%4 {
+			return 0, fmt.Errorf("job %d: attempt %d failed", job, attempt)
+		}
+		return job * job, nil
+	}, WithRetries(2, 20*time.Millisecond))
+
+	go func() {
+		for j := 1; j <= numJobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	var succeeded, failed int
+	for result := range pool.Results() {
+		mutex.Lock()
+		tries := attempts[result.Job]
+		mutex.Unlock()
+
+		if result.Err != nil {
+			failed++
+			console.Detailf("❌ job %d gave up after %d attempts: %v\n", result.Job, tries, result.Err)
+			continue
+		}
+		succeeded++
+		console.Detailf("✅ job %d -> %d after %d attempt(s)\n", result.Job, result.Value, tries)
+	}
+
+	console.Printf("\n📊 %d succeeded, %d failed\n", succeeded, failed)
+}
+
+func runWorkerPoolCPUDemo() {
+	console.Println("🧮 === CPU-bound Work Sized to the Machine ===")
+	console.Printf("Counting primes in 16 ranges with one worker per CPU (%d)\n", runtime.GOMAXPROCS(0))
+	console.Println()
+
+	const ranges = 16
+	const rangeSize = 100_000
+
+	run := func(pool *Pool[int, int]) (int, time.Duration) {
+		start := time.Now()
+		go func() {
+			for r := 0; r < ranges; r++ {
+				pool.Submit(r * rangeSize)
+			}
+			pool.Close()
+		}()
+
+		var primes int
+		for count := range pool.Results() {
+			primes += count
+		}
+		return primes, time.Since(start)
+	}
+
+	countRange := func(from int) int {
+		return countPrimes(from, from+rangeSize)
+	}
+
+	primes, single := run(NewPool(1, countRange))
+	console.Printf("1 worker:  %d primes in %v\n", primes, single.Round(time.Millisecond))
+
+	auto := NewPoolAuto(countRange)
+	workers := auto.Workers()
+	primes, parallel := run(auto)
+	console.Printf("%d workers: %d primes in %v\n", workers, primes, parallel.Round(time.Millisecond))
+
+	console.Printf("\nSpeedup: %.2fx\n", float64(single)/float64(parallel))
+}
+
+// countPrimes counts the primes in [from, to) by trial division.
+func countPrimes(from, to int) int {
+	var count int
+	for n := max(from, 2); n < to; n++ {
+		prime := true
+		for d := 2; d*d <= n; d++ {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			count++
+		}
+	}
+	return count
+}
+
+func runWorkerPoolSequential(ctx context.Context, numJobs int) []int {
+	var results []int
+	for j := 1; j <= numJobs && ctx.Err() == nil; j++ {
+		results = append(results, processJob(j)) // Same work as the concurrent version
+	}
+	
+	console.Printf("Completed %d jobs sequentially\n", len(results))
+	return results
+}
+
+// processJob is the work done per job in the comparison demo. Jobs take 50,
+// 100 or 150ms depending on their value, so the concurrent and sequential
+// runs are compared on the same uneven workload.
+func processJob(job int) int {
+	time.Sleep(time.Duration(job%3+1) * 50 * time.Millisecond) // Simulate work
+	return job * job
+}
+
+func simulateJob(job int) int {
+	time.Sleep(100 * time.Millisecond) // Simulate work
+	return job
 }
\ No newline at end of file
@@ -0,0 +1,106 @@
+package patterns
+
+import (
+	"container/heap"
+	"context"
+)
+
+// priorityJob is a job waiting in a pool's priority queue. seq keeps jobs of
+// equal priority in submission order.
+type priorityJob[J any] struct {
+	job      J
+	priority int
+	seq      int64
+}
+
+// priorityQueue is a max-heap of jobs ordered by priority.
+type priorityQueue[J any] []priorityJob[J]
+
+func (q priorityQueue[J]) Len() int { return len(q) }
+
+func (q priorityQueue[J]) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q priorityQueue[J]) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *priorityQueue[J]) Push(x any) { *q = append(*q, x.(priorityJob[J])) }
+
+func (q *priorityQueue[J]) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+// SubmitWithPriority queues job on the pool's priority queue without
+// blocking. A dispatcher goroutine hands the highest-priority waiting job to
+// the next free worker, so urgent jobs overtake ones submitted earlier.
+//
+// Ordering is best effort only: the dispatcher is already holding the next
+// job while it waits for a worker, jobs sent with Submit or sitting in the
+// WithQueueSize buffer are not reordered, and workers running concurrently
+// may finish jobs out of order. Like Submit, it discards job if the pool has
+// been cancelled or shut down and panics if the pool has been closed.
+func (p *Pool[J, R]) SubmitWithPriority(job J, priority int) {
+	if p.stopping() {
+		return
+	}
+
+	p.priorityMutex.Lock()
+	defer p.priorityMutex.Unlock()
+
+	if p.priorityClosed {
+		panic("patterns: Submit on closed worker pool")
+	}
+	if !p.dispatching {
+		p.dispatching = true
+		go p.dispatch()
+	}
+
+	heap.Push(&p.priority, priorityJob[J]{job: job, priority: priority, seq: p.prioritySeq})
+	p.prioritySeq++
+	p.queued.Add(1)
+	p.priorityCond.Signal()
+}
+
+// dispatch feeds the workers from the priority queue until it is closed and
+// empty, or the pool stops, in which case the remaining jobs are discarded.
+func (p *Pool[J, R]) dispatch() {
+	defer close(p.dispatched)
+	for {
+		p.priorityMutex.Lock()
+		for len(p.priority) == 0 && !p.priorityClosed && !p.stopping() {
+			p.priorityCond.Wait()
+		}
+		if len(p.priority) == 0 || p.stopping() {
+			p.queued.Add(-int64(len(p.priority)))
+			p.priority = nil
+			p.priorityMutex.Unlock()
+			return
+		}
+		next := heap.Pop(&p.priority).(priorityJob[J])
+		p.queued.Add(-1)
+		p.priorityMutex.Unlock()
+
+		p.submit(context.Background(), next.job, true)
+	}
+}
+
+// closePriority stops the priority queue from accepting jobs and waits for
+// the dispatcher to hand out, or discard, what is left in it.
+func (p *Pool[J, R]) closePriority() {
+	p.priorityMutex.Lock()
+	p.priorityClosed = true
+	started := p.dispatching
+	p.priorityCond.Broadcast()
+	p.priorityMutex.Unlock()
+
+	if started {
+		<-p.dispatched
+	}
+}
@@ -0,0 +1,670 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// collect drains a pool's results.
+func collect[R any](results <-chan R) []R {
+	var all []R
+	for result := range results {
+		all = append(all, result)
+	}
+	return all
+}
+
+func square(n int) int { return n * n }
+
+func TestPoolProcessesEveryJob(t *testing.T) {
+	tests := []struct {
+		workers, jobs int
+	}{
+		{1, 5},
+		{3, 20},
+		{8, 3},
+	}
+	for _, tt := range tests {
+		pool := NewPool(tt.workers, square)
+		go func() {
+			for j := 1; j <= tt.jobs; j++ {
+				pool.Submit(j)
+			}
+			pool.Close()
+		}()
+
+		got := collect(pool.Results())
+		slices.Sort(got)
+		want := make([]int, tt.jobs)
+		for i := range want {
+			want[i] = square(i + 1)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%d workers: results %v, want %v", tt.workers, got, want)
+		}
+		if pool.Completed() != tt.jobs {
+			t.Errorf("%d workers: Completed = %d, want %d", tt.workers, pool.Completed(), tt.jobs)
+		}
+	}
+}
+
+func TestPoolWorkerStats(t *testing.T) {
+	const workers, jobs = 4, 40
+	pool := NewPool(workers, func(n int) int {
+		time.Sleep(2 * time.Millisecond) // long enough for every worker to get a turn
+		return n
+	})
+	go func() {
+		for j := 1; j <= jobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+	collect(pool.Results())
+
+	stats := pool.WorkerStats()
+	if len(stats) != workers {
+		t.Fatalf("WorkerStats has %d workers, want %d", len(stats), workers)
+	}
+	var total int
+	for id, worker := range stats {
+		if worker.Jobs == 0 {
+			t.Errorf("worker %d did no work", id)
+		}
+		total += worker.Jobs
+	}
+	if total != jobs {
+		t.Errorf("workers did %d jobs between them, want %d", total, jobs)
+	}
+}
+
+func TestPoolManyJobsDoNotDeadlock(t *testing.T) {
+	const jobs = 1000
+	pool := NewPool(4, square, WithQueueSize(1))
+	go func() {
+		for j := 1; j <= jobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	done := make(chan int)
+	go func() { done <- len(collect(pool.Results())) }()
+	select {
+	case got := <-done:
+		if got != jobs {
+			t.Errorf("got %d results, want %d", got, jobs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool stalled before delivering every result")
+	}
+}
+
+func TestWorkerPoolMatchesSequential(t *testing.T) {
+	quietOutput(t)
+	const jobs = 6
+	concurrent := runWorkerPoolConcurrent(context.Background(), 3, jobs)
+	sequential := runWorkerPoolSequential(context.Background(), jobs)
+	if !sameResults(concurrent, sequential) || len(sequential) != jobs {
+		t.Errorf("concurrent results %v, sequential %v; want the same %d", concurrent, sequential, jobs)
+	}
+}
+
+func TestNewPoolValidates(t *testing.T) {
+	expectPanic(t, "NewPool with no workers", func() { NewPool(0, square) })
+	expectPanic(t, "ForEachN with no limit", func() { ForEachN([]int{1}, 0, func(int) {}) })
+}
+
+func TestNewPoolAuto(t *testing.T) {
+	tests := []struct {
+		opts []PoolOption
+		want int
+	}{
+		{nil, runtime.GOMAXPROCS(0)},
+		{[]PoolOption{WithWorkers(2)}, 2},
+	}
+	for _, tt := range tests {
+		pool := NewPoolAuto(square, tt.opts...)
+		if got := pool.Workers(); got != tt.want {
+			t.Errorf("Workers = %d, want %d", got, tt.want)
+		}
+		pool.Close()
+		collect(pool.Results())
+	}
+}
+
+func TestTryPoolReportsErrorsAndPanics(t *testing.T) {
+	errOdd := errors.New("odd job")
+	pool := NewTryPool(2, func(n int) (int, error) {
+		switch {
+		case n == 3:
+			panic("job 3 exploded")
+		case n%2 == 1:
+			return 0, errOdd
+		}
+		return n * 10, nil
+	})
+	go func() {
+		for j := 1; j <= 4; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	results := make(map[int]JobResult[int, int])
+	for result := range pool.Results() {
+		results[result.Job] = result
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	if !errors.Is(results[1].Err, errOdd) {
+		t.Errorf("job 1: Err = %v, want %v", results[1].Err, errOdd)
+	}
+	var panicErr *PanicError
+	if !errors.As(results[3].Err, &panicErr) || panicErr.Value != "job 3 exploded" {
+		t.Errorf("job 3: Err = %v, want a *PanicError", results[3].Err)
+	}
+	for _, job := range []int{2, 4} {
+		if results[job].Err != nil || results[job].Value != job*10 {
+			t.Errorf("job %d: got %+v", job, results[job])
+		}
+	}
+}
+
+func TestPoolPanicWithoutResultType(t *testing.T) {
+	pool := NewPool(1, func(n int) int {
+		if n == 2 {
+			panic("boom")
+		}
+		return n
+	})
+	go func() {
+		for j := 1; j <= 3; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+
+	got := collect(pool.Results())
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("results %v, want the panicking job dropped and the rest processed", got)
+	}
+}
+
+// gatedJobs returns a job function that blocks until release is closed,
+// counting how many jobs have started.
+func gatedJobs() (fn func(int) int, started *atomic.Int64, release chan struct{}) {
+	started = new(atomic.Int64)
+	release = make(chan struct{})
+	fn = func(n int) int {
+		started.Add(1)
+		<-release
+		return n
+	}
+	return fn, started, release
+}
+
+func TestPoolContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fn, started, release := gatedJobs()
+	pool := NewPool(2, fn, WithPoolContext(ctx), WithQueueSize(10))
+
+	for j := 1; j <= 6; j++ {
+		pool.Submit(j)
+	}
+	waitFor(t, "two running jobs", func() bool { return started.Load() == 2 })
+	cancel()
+	close(release)
+
+	done := make(chan []int)
+	go func() { done <- collect(pool.Results()) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Results did not close after the pool was cancelled")
+	}
+	if started.Load() != 2 {
+		t.Errorf("%d jobs started, want the queued ones discarded", started.Load())
+	}
+	if err := pool.SubmitContext(context.Background(), 7); !errors.Is(err, ErrPoolStopped) {
+		t.Errorf("SubmitContext after cancel = %v, want ErrPoolStopped", err)
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{"running jobs finish", context.Background(), nil},
+		{"gives up when ctx is done", expired, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, started, release := gatedJobs()
+			pool := NewPool(1, fn, WithQueueSize(5))
+			go collect(pool.Results())
+
+			for j := 1; j <= 3; j++ {
+				pool.Submit(j)
+			}
+			waitFor(t, "the first job", func() bool { return started.Load() == 1 })
+
+			if tt.want == nil {
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					close(release)
+				}()
+			} else {
+				defer close(release)
+			}
+			if err := pool.Shutdown(tt.ctx); !errors.Is(err, tt.want) {
+				t.Fatalf("Shutdown = %v, want %v", err, tt.want)
+			}
+			if started.Load() != 1 {
+				t.Errorf("%d jobs started, want the queued ones skipped", started.Load())
+			}
+			if err := pool.SubmitContext(context.Background(), 4); !errors.Is(err, ErrPoolStopped) {
+				t.Errorf("SubmitContext after Shutdown = %v, want ErrPoolStopped", err)
+			}
+		})
+	}
+}
+
+func TestPoolCloseWithTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		block bool
+		want  bool
+	}{
+		{"drains in time", false, true},
+		{"times out", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, _, release := gatedJobs()
+			if !tt.block {
+				close(release)
+			} else {
+				defer close(release)
+			}
+			pool := NewPool(1, fn, WithQueueSize(3))
+			go collect(pool.Results())
+			for j := 1; j <= 3; j++ {
+				pool.Submit(j)
+			}
+
+			if got := pool.CloseWithTimeout(50 * time.Millisecond); got != tt.want {
+				t.Errorf("CloseWithTimeout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolJobTimeout(t *testing.T) {
+	pool := NewContextPool(2, func(ctx context.Context, d time.Duration) (time.Duration, error) {
+		select {
+		case <-time.After(d):
+			return d, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}, WithJobTimeout(20*time.Millisecond))
+	go func() {
+		pool.Submit(time.Millisecond)
+		pool.Submit(time.Second)
+		pool.Close()
+	}()
+
+	for result := range pool.Results() {
+		switch result.Job {
+		case time.Millisecond:
+			if result.Err != nil {
+				t.Errorf("fast job: Err = %v", result.Err)
+			}
+		case time.Second:
+			if !errors.Is(result.Err, ErrJobTimeout) {
+				t.Errorf("slow job: Err = %v, want ErrJobTimeout", result.Err)
+			}
+		}
+	}
+}
+
+func TestPoolTrySubmit(t *testing.T) {
+	fn, started, release := gatedJobs()
+	pool := NewPool(1, fn, WithQueueSize(1))
+	go collect(pool.Results())
+	defer pool.Close()
+
+	if !pool.TrySubmit(1) {
+		// No worker may be waiting yet; fall back to a blocking submit.
+		pool.Submit(1)
+	}
+	waitFor(t, "the first job", func() bool { return started.Load() == 1 })
+
+	tests := []struct {
+		job  int
+		want bool
+	}{
+		{2, true},  // fits in the queue
+		{3, false}, // queue full
+	}
+	for _, tt := range tests {
+		if got := pool.TrySubmit(tt.job); got != tt.want {
+			t.Errorf("TrySubmit(%d) = %v, want %v", tt.job, got, tt.want)
+		}
+	}
+
+	// A blocking Submit goes through once the worker frees a slot
+	submitted := make(chan struct{})
+	go func() {
+		pool.Submit(4)
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit returned while the queue was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("Submit stayed blocked after the worker drained the queue")
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	const workers, jobs = 2, 8
+	pool := NewPool(workers, func(n int) int {
+		time.Sleep(5 * time.Millisecond)
+		return n
+	}, WithQueueSize(jobs))
+	go collect(pool.Results())
+	for j := 1; j <= jobs; j++ {
+		pool.Submit(j)
+	}
+	pool.Close()
+
+	waitFor(t, "every job to complete", func() bool {
+		stats := pool.Stats()
+		if stats.Active > workers {
+			t.Fatalf("Stats = %+v, more active jobs than the %d workers", stats, workers)
+		}
+		return stats.Completed == jobs
+	})
+}
+
+func TestPoolAutoscale(t *testing.T) {
+	fn, started, release := gatedJobs()
+	pool := NewPool(1, fn, WithAutoscale(3, 1, 20*time.Millisecond), WithQueueSize(10))
+	go collect(pool.Results())
+
+	for j := 1; j <= 8; j++ {
+		pool.Submit(j)
+	}
+	waitFor(t, "the pool to grow", func() bool { return started.Load() == 3 })
+	if got := pool.Workers(); got != 3 {
+		t.Errorf("Workers = %d under load, want the maximum of 3", got)
+	}
+
+	close(release)
+	waitFor(t, "the jobs to finish", func() bool { return pool.Completed() == 8 })
+	waitFor(t, "idle workers to retire", func() bool { return pool.Workers() == 1 })
+	pool.Close()
+}
+
+func TestPoolPriority(t *testing.T) {
+	type job struct {
+		name     string
+		priority int
+	}
+
+	var mutex sync.Mutex
+	var order []string
+	release := make(chan struct{})
+	pool := NewPool(1, func(j job) string {
+		if j.name == "gate" {
+			<-release
+		}
+		mutex.Lock()
+		order = append(order, j.name)
+		mutex.Unlock()
+		return j.name
+	})
+	go collect(pool.Results())
+
+	// Occupy the worker, then let the dispatcher take and hold a first job
+	// so that everything after it is ordered purely by the queue.
+	pool.Submit(job{name: "gate"})
+	pool.SubmitWithPriority(job{name: "held"}, 0)
+	waitFor(t, "the dispatcher to take the first job", func() bool {
+		pool.priorityMutex.Lock()
+		defer pool.priorityMutex.Unlock()
+		return len(pool.priority) == 0
+	})
+
+	jobs := []job{{"low", 1}, {"high-a", 5}, {"mid", 3}, {"urgent", 9}, {"high-b", 5}}
+	for _, j := range jobs {
+		pool.SubmitWithPriority(j, j.priority)
+	}
+	close(release)
+	pool.Close()
+
+	want := []string{"gate", "held", "urgent", "high-a", "high-b", "mid", "low"}
+	if !slices.Equal(order, want) {
+		t.Errorf("processed %v, want %v", order, want)
+	}
+}
+
+func TestPoolRetries(t *testing.T) {
+	var mutex sync.Mutex
+	attempts := make(map[string]int)
+	errFlaky := errors.New("flaky")
+
+	pool := NewTryPool(2, func(name string) (string, error) {
+		mutex.Lock()
+		attempts[name]++
+		n := attempts[name]
+		mutex.Unlock()
+
+		switch {
+		case name == "broken":
+			return "", errFlaky
+		case name == "flaky" && n < 3:
+			return "", errFlaky
+		}
+		return name, nil
+	}, WithRetries(2, time.Millisecond))
+	go func() {
+		for _, name := range []string{"ok", "flaky", "broken"} {
+			pool.Submit(name)
+		}
+		pool.Close()
+	}()
+
+	errs := make(map[string]error)
+	for result := range pool.Results() {
+		errs[result.Job] = result.Err
+	}
+
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+	}{
+		{"ok", 1, nil},
+		{"flaky", 3, nil},
+		{"broken", 3, errFlaky},
+	}
+	for _, tt := range tests {
+		if attempts[tt.name] != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts[tt.name], tt.attempts)
+		}
+		if !errors.Is(errs[tt.name], tt.err) || (tt.err == nil && errs[tt.name] != nil) {
+			t.Errorf("%s: Err = %v, want %v", tt.name, errs[tt.name], tt.err)
+		}
+	}
+}
+
+func TestPoolProgress(t *testing.T) {
+	const jobs = 12
+	var calls atomic.Int64
+	var highest atomic.Int64
+	pool := NewPool(4, square, WithOnProgress(jobs, func(done, total int) {
+		calls.Add(1)
+		if total != jobs {
+			t.Errorf("progress total = %d, want %d", total, jobs)
+		}
+		for {
+			h := highest.Load()
+			if int64(done) <= h || highest.CompareAndSwap(h, int64(done)) {
+				break
+			}
+		}
+	}))
+	go func() {
+		for j := 1; j <= jobs; j++ {
+			pool.Submit(j)
+		}
+		pool.Close()
+	}()
+	collect(pool.Results())
+
+	if calls.Load() != jobs || highest.Load() != jobs {
+		t.Errorf("progress called %d times reaching %d, want %d and %d", calls.Load(), highest.Load(), jobs, jobs)
+	}
+}
+
+func TestProgressPrinterSkipsRegressions(t *testing.T) {
+	buf := captureOutput(t)
+	setLogLevel(t, LevelVerbose)
+
+	printProgress := newProgressPrinter()
+	for _, done := range []int{2, 1, 3, 4} {
+		printProgress(done, 4)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"2/4", "3/4", "4/4\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q is missing %q", out, want)
+		}
+	}
+	if strings.Contains(out, "1/4") {
+		t.Errorf("output %q moved the bar backwards", out)
+	}
+}
+
+func TestForEachN(t *testing.T) {
+	tests := []struct {
+		items, limit int
+	}{
+		{10, 1},
+		{10, 3},
+		{2, 5},
+	}
+	for _, tt := range tests {
+		var running, peak, sum atomic.Int64
+		items := make([]int, tt.items)
+		for i := range items {
+			items[i] = i + 1
+		}
+
+		ForEachN(items, tt.limit, func(n int) {
+			now := running.Add(1)
+			for {
+				p := peak.Load()
+				if now <= p || peak.CompareAndSwap(p, now) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			sum.Add(int64(n))
+			running.Add(-1)
+		})
+
+		if want := int64(tt.items * (tt.items + 1) / 2); sum.Load() != want {
+			t.Errorf("limit %d: sum %d, want %d", tt.limit, sum.Load(), want)
+		}
+		if peak.Load() > int64(tt.limit) {
+			t.Errorf("limit %d: %d calls ran at once", tt.limit, peak.Load())
+		}
+	}
+}
+
+func TestProcessAll(t *testing.T) {
+	errBad := errors.New("bad job")
+	tests := []struct {
+		name    string
+		jobs    []int
+		want    []int
+		wantErr bool
+	}{
+		{"in order", []int{5, 1, 4, 2}, []int{25, 1, 16, 4}, false},
+		{"failed jobs are zero", []int{1, -2, 3, -4}, []int{1, 0, 9, 0}, true},
+		{"no jobs", nil, []int{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProcessAll(3, tt.jobs, func(n int) (int, error) {
+				if n < 0 {
+					return 0, errBad
+				}
+				return n * n, nil
+			})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("results %v, want %v", got, tt.want)
+			}
+			if (err != nil) != tt.wantErr || (tt.wantErr && !errors.Is(err, errBad)) {
+				t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for j := 1; j <= 10; j++ {
+			in <- j
+		}
+	}()
+
+	var sum int
+	for result := range Stream(3, in, square) {
+		sum += result
+	}
+	if sum != 385 {
+		t.Errorf("sum of squares = %d, want 385", sum)
+	}
+}
+
+func TestWorkerPoolComparisonCancelled(t *testing.T) {
+	quietOutput(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := WorkerPoolComparison(ctx)
+	if !summary.Cancelled {
+		t.Error("summary not marked as cancelled")
+	}
+	if summary.Sequential != 0 {
+		t.Error("the sequential baseline ran after cancellation")
+	}
+}